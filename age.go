@@ -0,0 +1,89 @@
+package memoryguard
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// userHZ is the clock ticks per second of the times in /proc/[pid]/stat: USER_HZ, which sysconf(_SC_CLK_TCK)
+// and AT_CLKTCK report. The kernel fixes it at 100 on every architecture but alpha and ia64, which Go doesn't
+// support, so it isn't read.
+const userHZ = 100
+
+// ProcessAge returns how long the process has been running, per its start time in procfs, or an error.
+func (m *MemoryGuard) ProcessAge() (time.Duration, error) {
+	if m.proc == nil {
+		return 0, LimitNilProcessError
+	}
+	return processAge(m.procRoot(), m.proc.Pid)
+}
+
+// tooYoung returns the age of the process, and true if MinProcessAge is set, and it is younger than that.
+// If the age can't be read, it is not too young.
+func (m *MemoryGuard) tooYoung() (time.Duration, bool) {
+	minAge := m.conf().minProcessAge
+	if minAge <= 0 {
+		return 0, false
+	}
+	age, err := m.ProcessAge()
+	if err != nil {
+		return 0, false
+	}
+	return age, age < minAge
+}
+
+// processAge takes a procfs root and a pid, and returns how long the process has been running, or an error.
+func processAge(root string, pid int) (time.Duration, error) {
+	start, err := procStartTime(root, pid)
+	if err != nil {
+		return 0, err
+	}
+	up, err := uptime(root)
+	if err != nil {
+		return 0, err
+	}
+	return max(up-start, 0), nil
+}
+
+// procStartTime takes a procfs root and a pid, and returns when the process started, as time since boot,
+// from stat, or an error.
+func procStartTime(root string, pid int) (time.Duration, error) {
+	stat, err := os.ReadFile(procPath(root, pid, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// starttime is the 22nd field, the 20th after the parenthesized comm, which may itself contain spaces or parens.
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, StatFormatError
+	}
+	fields := bytes.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return 0, StatFormatError
+	}
+	ticks, err := strconv.ParseInt(string(fields[19]), 10, 64)
+	if err != nil {
+		return 0, StatFormatError
+	}
+	return time.Duration(ticks) * time.Second / userHZ, nil
+}
+
+// uptime takes a procfs root, and returns the time since boot, or an error.
+func uptime(root string) (time.Duration, error) {
+	b, err := os.ReadFile(filepath.Join(root, "uptime"))
+	if err != nil {
+		return 0, err
+	}
+	fields := bytes.Fields(b)
+	if len(fields) < 1 {
+		return 0, StatFormatError
+	}
+	secs, err := strconv.ParseFloat(string(fields[0]), 64)
+	if err != nil {
+		return 0, StatFormatError
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
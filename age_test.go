@@ -0,0 +1,67 @@
+package memoryguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeAge writes a stat for pid 1 that started 90s after boot, and an uptime of up, to root.
+func fakeAge(root string, up string) {
+	os.MkdirAll(filepath.Join(root, "1"), 0755)
+	os.WriteFile(filepath.Join(root, "1", "stat"), []byte("1 (my (odd) proc) S 0 1 1 0 -1 4194560 1 0 0 0 0 0 0 0 20 0 1 0 9000 1000 100 18446744073709551615\n"), 0644)
+	os.WriteFile(filepath.Join(root, "uptime"), []byte(up+" 400.00\n"), 0644)
+}
+
+func Test_ProcessAge(t *testing.T) {
+	Convey("When the age of our process is read", t, func() {
+		age, err := processAge(DefaultProcRoot, os.Getpid())
+		So(err, ShouldBeNil)
+		So(age, ShouldBeGreaterThanOrEqualTo, 0)
+		So(age, ShouldBeLessThan, 24*time.Hour)
+	})
+
+	Convey("When the age of a process is read from a fake procfs", t, func() {
+		root := t.TempDir()
+		fakeAge(root, "100.50")
+		age, err := processAge(root, 1)
+		So(err, ShouldBeNil)
+		So(age, ShouldEqual, 10500*time.Millisecond)
+
+		os.WriteFile(filepath.Join(root, "1", "stat"), []byte("1 (short) S 0\n"), 0644)
+		_, err = processAge(root, 1)
+		So(err, ShouldEqual, StatFormatError)
+	})
+}
+
+func Test_MemoryGuardMinProcessAge(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard with a MinProcessAge guards a young process", t, func() {
+		root := t.TempDir()
+		fakeAge(root, "100.00")
+		mg := New(&os.Process{Pid: 1}, WithProcRoot(root), WithMinProcessAge(time.Minute))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		mg.SimulateChan <- 2000
+		mg.SimulateChan <- 2000 // the second value can't be received until the first is processed
+		mg.SimulateChan <- 2000
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+		age, _ := mg.ProcessAge()
+		So(age, ShouldEqual, 10*time.Second)
+
+		Convey("it isn't killed until it's old enough", func() {
+			fakeAge(root, "200.00")
+			mg.SimulateChan <- 2000
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerLimit)
+		})
+	})
+}
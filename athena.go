@@ -3,12 +3,20 @@
 package memoryguard
 
 import (
-	"bufio"
 	"bytes"
-	"fmt"
+	"context"
+	"errors"
 	"io"
 	"log"
+	"maps"
+	"math"
+	"math/rand/v2"
 	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,65 +24,432 @@ import (
 	"github.com/cognusion/go-humanity"
 )
 
+// DefaultProcRoot is the default ProcRoot
+const DefaultProcRoot = "/proc"
+
+const (
+	// unknownLimit is a placeholder limit used until LimitRelative has a baseline
+	unknownLimit = math.MaxInt64
+	// monitorLimit is a placeholder limit used by NewMonitor, which never enforces it
+	monitorLimit = math.MaxInt64 - 1
+)
+
 // MemoryGuard is our encapsulating mechanation, and should only be acquired via a New helper.
-// Member functions are goro-safe, but all struct fields should be set immediatelyish after New(),
-// and before Limit() is called.
+// Member functions are goro-safe, and all struct fields should be set immediatelyish after New()
+// (or via Options to New()), and before Limit() is called. Limit() takes a snapshot of the fields,
+// so changing them afterward has no effect.
 type MemoryGuard struct {
 	// Name is a name to use in lieu of PID for messaging
 	Name string
+	// NameFrom is where the name comes from if Name is unset. It is read once, when Limit() is called.
+	// Default is NameFromPID.
+	NameFrom NameFrom
+	// ShouldKill, if set, replaces the default test of whether a sample is over the limit (sample > limit), for
+	// policies such as hysteresis or custom smoothing. It is given the sample (the mean, if AvgWindow is set), the
+	// limit, and up to the last 32 samples, oldest first, including this one. It is called synchronously on the
+	// Limit goro (or by CheckNow) for every sample, so it must be fast. It is not called if CompositeMetrics are
+	// set, and the other triggers (PSI, GrowthFraction, etc.) still apply. Default is nil, which uses sample > limit.
+	ShouldKill func(sample, limit int64, history []Sample) bool
+	// MaxMappings, if set, acts on the process when a sample sums more mappings than it, as some leaks are an
+	// explosion of mappings (e.g. an mmap leak) more than of memory. It requires a Metric that reads smaps
+	// (i.e. not MetricRSSFast or MetricMaxRSS), and isn't for cgroups: Limit() returns MaxMappingsMetricError
+	// otherwise. See MappingCount. Default is 0, which disables it.
+	MaxMappings int
+	// MaxThreads, if set, also reads the Threads count from status with each sample, and acts on the process
+	// when it exceeds MaxThreads, to catch thread leaks alongside memory ones. See ThreadCount and OnThreads.
+	// Default is 0, which disables it (and the extra read).
+	MaxThreads int
+	// OnThreads, if set, is called instead of acting on the process when its Threads count climbs over
+	// MaxThreads, with the count and MaxThreads. Like a threshold, it isn't called again until the count has
+	// dropped to MaxThreads or below, and climbed back over.
+	OnThreads func(threads, max int)
+	// SkipLimitCheck, if true, skips the check at Limit() that the limit can be reached at all, i.e. isn't more
+	// than the memory of the system, or of the cgroups the process is in, which otherwise logs a warning.
+	SkipLimitCheck bool
+	// ImmediateEnforce, if true, makes a SetLimit below the last sample enforced at the very next sample, rather
+	// than after LowerLimitGrace.
+	ImmediateEnforce bool
+	// LowerLimitGrace is how long the previous limit continues to be enforced after SetLimit lowers it below the
+	// last sample, unless ImmediateEnforce is set. Default is 0, which is DefaultLowerLimitGrace.
+	LowerLimitGrace time.Duration
+	// LimitSchedule, if set, are windows of the day with their own limits, e.g. a looser one overnight when a batch
+	// job legitimately uses more. Every sample, the Limit of the first open ScheduleWindow (per the Clock, in its
+	// Location) is used instead of that given to Limit(), which remains the default outside of every window.
+	// It doesn't apply to LimitRelative, and SetLimit overrides it until ClearLimit. See ScheduledLimit.
+	LimitSchedule []ScheduleWindow
+	// Labels are arbitrary key/value pairs (e.g. tenant, job, region) that the MemoryGuard carries but doesn't
+	// interpret: they are added to the KillEvent, BreachContext, and the key/value pairs given to LogFunc.
+	// They are copied when Limit() is called.
+	Labels map[string]string
 	// Interval is a time.Duration to wait between checking usage
 	Interval time.Duration
+	// AdaptInterval, if true, lengthens the Interval while the MemoryGuard is Overrunning, so that the next sample
+	// is at least as far from the end of the last as it took. Default is false, which only logs a warning.
+	AdaptInterval bool
+	// IntervalJitter, if set, adds a random duration in [0, IntervalJitter) to each Interval, so that many
+	// MemoryGuards with the same Interval don't all sample at once. Each MemoryGuard is seeded differently.
+	// Default is 0, which samples exactly every Interval.
+	IntervalJitter time.Duration
 	// DebugOut is a logger for debug information
 	DebugOut *log.Logger
 	// ErrOut is a logger for StdErr coming from a process
 	ErrOut *log.Logger
-	// KillChan will be closed if/when the process is killed
+	// KillChan will be closed if/when the process is killed. Reset replaces it, so code that may outlive
+	// a Reset should use KillNotify instead. To wait for the Limit goro to stop for any reason, use Done().
 	KillChan chan struct{}
+	// CloseKillChanOnStop, if true, also closes KillChan when the Limit goro stops without killing (e.g.
+	// Cancel), so code that only waits on KillChan isn't blocked forever. StopReason distinguishes the two.
+	// Default is false.
+	CloseKillChanOnStop bool
+	// ExitChan will be closed if/when the process is found to have exited on its own, i.e. without being killed.
+	// Reset replaces it.
+	ExitChan chan struct{}
 	// KillError will be any error returned by the "Kill" operation. Varies widely by OS. Usually nil.
 	KillError error
-	// StatsFrequency updates the internal frequency to which statistics are emitted to the debug logger. Default is 1 minute.
+	// StatsFrequency updates the internal frequency to which statistics are emitted to the debug logger,
+	// independent of Interval. Default is 1 minute. Zero disables statistics.
 	StatsFrequency time.Duration
+	// KillSignal is an optional signal to send the process in lieu of os.Kill. If KillConfirmTimeout is set,
+	// and the process hasn't died within it, the process will be escalated to os.Kill.
+	KillSignal os.Signal
+	// PreKillSignal, if set, is sent to the process before KillSignal, and PreKillDelay waited, e.g. so a Go
+	// process with a SIGUSR1 handler can write a heap profile at the moment of breach. This is for diagnostics,
+	// not shutdown (see OnShutdown), so the process is killed after PreKillDelay whether it has exited or not.
+	PreKillSignal os.Signal
+	// PreKillDelay is how long to wait after sending PreKillSignal before killing the process. Default is 0.
+	PreKillDelay time.Duration
+	// KillConfirmTimeout is how long to wait for the process to be confirmed dead after signalling it.
+	// Default is 0, which does not confirm.
+	KillConfirmTimeout time.Duration
+	// Metric is the memory measurement to sample and compare against the limit. Default is MetricPSS.
+	Metric Metric
+	// IncludeChildren, if true, makes each sample the sum of the Metric for the process and all of its
+	// descendants, found by scanning procfs, so a process tree (e.g. a pre-forking server and its workers) is
+	// guarded as a whole. Only the process itself is killed, unless KillGroup is also set. The usage of each
+	// descendant is kept from the sample, see Children, and the largest is the KillEvent's TopChild. It costs a
+	// read of every process's stat, and a sample of every descendant, with each sample. Ignored for cgroups,
+	// and MetricMaxRSS. Default is false.
+	IncludeChildren bool
+	// ConfirmWithPSS, if true, samples in two tiers to keep the overhead low: the cheap RSS (as MetricRSSFast)
+	// every Interval, and the accurate Metric (the smaps scan) only when the RSS is within PSSBand of the
+	// EffectiveLimit, or over it. As Pss never exceeds Rss, a process can't be over the limit by MetricPSS or
+	// MetricPSSShmem while its RSS is under it, so the decision to kill is always made on the accurate Metric.
+	// It only applies to those two, as hugetlbfs pages aren't in the RSS, and MappingWeights may be over 1, and
+	// Limit() logs that it is ignored for any other Metric, or a cgroup. Samples far from the limit are the RSS,
+	// which overstates shared memory, and are what PSS(), thresholds, OnSample, etc. see, but they are kept out
+	// of GrowthWindow, OutlierFactor, AvgWindow, and MaxMappings, which only see the accurate Metric, so that
+	// switching between the two isn't mistaken for a jump. Default is false.
+	ConfirmWithPSS bool
+	// PSSBand is the fraction of the EffectiveLimit, below it, within which ConfirmWithPSS samples the accurate
+	// Metric, e.g. 0.1 for the top 10%. Default is 0, which only does so once the RSS is over the limit.
+	PSSBand float64
+	// PSIThreshold, if set, is a percentage of memory pressure stall (see PSIField) above which the process
+	// will be killed, regardless of its own usage, unless PSICombined is set. Default is 0, which ignores PSI.
+	PSIThreshold float64
+	// PSIField is which PSI average is compared against PSIThreshold. Default is PSISomeAvg10.
+	PSIField PSIField
+	// PSICombined, if true, requires both the limit AND PSIThreshold to be exceeded to kill the process for the
+	// limit, and PSI alone never does. The other triggers (GrowthFraction, MaxMappings, etc.) still apply.
+	PSICombined bool
+	// PSIPath is the pressure file to read PSI from. Default is "pressure/memory" under ProcRoot, but a cgroup v2
+	// memory.pressure file may be used instead.
+	PSIPath string
+	// ProcRoot is the root of the procfs to read process information from. Default is "/proc".
+	ProcRoot string
+	// LogFunc, if set, receives all events that would otherwise go to DebugOut and ErrOut.
+	LogFunc LogFunc
+	// MinAvailable, if set, is a floor for system available memory (MemAvailable from meminfo, in Bytes).
+	// The process will only be killed if it is over its limit AND system available memory is below this floor,
+	// so a large process on a lightly loaded system is left alone. If meminfo can't be read, the floor is
+	// assumed to be breached. Default is 0, which kills on the limit alone.
+	MinAvailable int64
+	// MinKillInterval, if set, is the minimum time between kills of processes guarded by MemoryGuards sharing
+	// the same Name (or PID, if Name is unset), so that a restarted process that immediately breaches its limit
+	// can't cause a kill storm. A suppressed kill is logged, and the guard carries on guarding. Default is 0.
+	MinKillInterval time.Duration
+	// OnDrain, if set along with DrainFraction, is called once when the sample crosses DrainFraction of the limit
+	// (as with AddThreshold), so that the process can stop accepting new work.
+	OnDrain func()
+	// DrainFraction is the fraction of the limit at which OnDrain is called, e.g. 0.8 for 80%.
+	DrainFraction float64
+	// OnShutdown, if set, is called when the limit is breached, before the process is killed, so that it can
+	// shut down gracefully. It is called in its own goro, and the MemoryGuard then waits up to ShutdownGrace
+	// for the process to exit on its own before killing it as usual. The order is thus: OnDrain, OnShutdown,
+	// ShutdownGrace, PreKillSignal, PreKillDelay, KillSignal, KillConfirmTimeout, os.Kill.
+	OnShutdown func()
+	// ShutdownGrace is how long to wait after calling OnShutdown for the process to exit before killing it.
+	// Default is 0, which kills immediately after calling OnShutdown.
+	ShutdownGrace time.Duration
+	// ShutdownFunc, if set, and the guarded process is our own, is called instead of killing it when the limit
+	// is breached, so that it can close listeners, cancel its root context, and exit after cleaning up. It is
+	// called in its own goro, and the breach is otherwise complete as usual (KillEvent, KillChan, etc.). When
+	// guarding ourselves, this is strongly preferred, as killing ourselves skips all deferred cleanup. It is
+	// ignored when guarding any other process.
+	ShutdownFunc func()
+	// OnBreach, if set, is called when the limit is breached, in lieu of the usual OnShutdown and kill, with
+	// everything known about the breach, and methods to act on it (e.g. BreachContext.Kill). If it returns true,
+	// the breach is complete as usual (KillEvent, KillChan, etc.), whether or not it killed the process. If it
+	// returns false, the breach is ignored, and it will be called again on the next sample over the limit.
+	// It is called synchronously, before the next sample.
+	OnBreach func(*BreachContext) bool
+	// OnKillDenied, if set, is called once if killing the process fails for lack of permission (e.g. it has
+	// changed users), after which the MemoryGuard degrades to ReportOnly: it keeps sampling, and logs breaches,
+	// but never closes KillChan, leaving it to the caller to take over.
+	OnKillDenied func(*KillEvent)
+	// OnSample, if set, is called with every successful sample, before it is compared to the limit. It is called
+	// synchronously, so it should return quickly.
+	OnSample func(Sample)
+	// OnRecover, if set, is called with the number of consecutive sampling errors when a successful sample
+	// follows them, once per recovery. It is called synchronously, so it should return quickly.
+	OnRecover func(afterErrors int)
+	// OnExit, if set, is called with the pid when the process is found to have exited on its own, i.e. without
+	// being killed, just before the Limit goro stops. It is called synchronously, so it should return quickly.
+	OnExit func(pid int)
+	// OnStats, if set, is called every StatsFrequency with the same GuardStats that are logged then, as a
+	// rate-limited alternative to OnSample for coarse reporting. It is called synchronously, so it should
+	// return quickly.
+	OnStats func(GuardStats)
+	// OnDemand, if true, prevents Limit() from starting a goro, and the caller must call CheckNow() on
+	// their own schedule instead. Default is false.
+	OnDemand bool
+	// ProfileLabels, if true, labels the Limit goro with the Name and PID of the process for pprof. Default is false.
+	ProfileLabels bool
+	// UsePidfd, if true, opens a pidfd (Linux 5.3+) for the process when Limit() is called, and signals it
+	// through that, guaranteeing the signal reaches the process being watched even if its PID has since been
+	// reused. Falls back to Process.Signal on older kernels. Default is false.
+	UsePidfd bool
+	// ReportTopMappings, if set, is the number of largest mappings by Pss to collect from smaps when the
+	// process is killed, for the KillEvent and ErrOut. This is an extra full smaps read. Default is 0.
+	ReportTopMappings int
+	// SampleBuffer is the buffer size of each channel returned by Samples(). Default is DefaultSampleBuffer.
+	SampleBuffer int
+	// SampleDropOldest, if true, drops the oldest buffered sample to make room for a new one when a Samples()
+	// subscriber falls behind. Default is false, which drops the new sample instead.
+	SampleDropOldest bool
+	// SampleTimeout, if set, is the maximum time a single sample may take before it is abandoned and
+	// treated as a sampling error. Useful when ProcRoot is on a slow or unreliable filesystem. Note that
+	// an abandoned read can't be interrupted, and will linger until the filesystem returns. Default is 0.
+	SampleTimeout time.Duration
+	// TrackOverhead, if true, measures the CPU time spent, and bytes read, by every sample, for Overhead. It costs
+	// a couple of extra syscalls per sample, and is only available on Linux. Default is false.
+	TrackOverhead bool
+	// SimulateChan, if set, puts the MemoryGuard in simulation mode: each value sent on it is used by the Limit goro
+	// as the next sample, and procfs is never read. Interval and TickSource are ignored, and on-demand samples return
+	// the last simulated value. Note that the Process will still be acted upon if a simulated value breaches the limit,
+	// unless DryRun is set.
+	SimulateChan chan int64
+	// DryRun, if true, does everything a breach would do (KillEvent, KillChan, etc.) except signal the process.
+	// Default is false.
+	DryRun bool
+	// KillGroup, if true, signals the whole process group of the process instead of just the process, with
+	// KillSignal and KillConfirmTimeout applying to every member: if any survive the KillSignal, only they are
+	// escalated to os.Kill. The process should lead its own group (e.g. started with SysProcAttr.Setpgid),
+	// as we refuse to kill our own. Only on Unix, and elsewhere the kill fails with KillGroupSignalError.
+	// Default is false.
+	KillGroup bool
+	// WarnFraction, if set, is the fraction of the limit (e.g. 0.8 for 80%) above which TimeOverWarn accrues.
+	// Default is 0, which never accrues.
+	WarnFraction float64
+	// MappingWeights are the weights of each MappingType for MetricWeightedPSS. A missing MappingType has a
+	// weight of 1.0, so the default of nil is plain PSS.
+	MappingWeights map[MappingType]float64
+	// QuiesceGC, if true, and the process is the current one (self-guarding), forces a garbage collection
+	// and returns freed memory to the OS before killing it, then samples again, and only kills it if it is
+	// still over the limit. It has no effect on any other process. Default is false.
+	QuiesceGC bool
+	// AvgWindow, if set, makes every decision (thresholds, limit, KillEvent Sample) on the mean of the samples
+	// taken within the last AvgWindow, rather than the latest sample, smoothing out transient spikes consistently
+	// regardless of Interval. Default is 0, which uses the latest sample.
+	AvgWindow time.Duration
+	// CompositeMetrics, if set, are additional Metrics compared against the limit along with Metric, and
+	// CompositeMode decides whether all of them, or any of them, must be over for the limit to be breached.
+	// Requiring all of them guards against one metric being momentarily misleading. Default is nil.
+	CompositeMetrics []Metric
+	// CompositeMode is how CompositeMetrics are combined with Metric. Default is CompositeAll.
+	CompositeMode CompositeMode
+	// DeadlineKill, if true, kills the process (TriggerDeadline) if the context given to NewWithContext passes
+	// its deadline while guarding. Otherwise the deadline just stops the guard, as does cancelling it. Default is false.
+	DeadlineKill bool
+	// GrowthWindow and GrowthFraction, if both set, also act on the process if a sample exceeds the rolling
+	// baseline, the lowest sample within the last GrowthWindow, by more than GrowthFraction of it (e.g. 0.5 for
+	// 50%). This adapts to processes whose legitimate footprint changes over time, while still catching runaway
+	// growth. Nothing is done until samples have been taken for a full GrowthWindow. Default is 0, which disables it.
+	GrowthWindow time.Duration
+	// GrowthFraction is the growth over the rolling baseline allowed by GrowthWindow.
+	GrowthFraction float64
+	// SystemdWatchdog, if true, notifies systemd with WATCHDOG=1 after every successful sample, so a service
+	// with WatchdogSec is only considered alive while it is being guarded. It is a no-op if NOTIFY_SOCKET is
+	// unset, or on platforms other than Linux. Default is false.
+	SystemdWatchdog bool
+	// MinProcessAge, if set, suppresses acting on the process until it has been running at least that long, per
+	// its start time in procfs, so a legitimate startup burst isn't fatal, however late the MemoryGuard attached.
+	// If the start time can't be read, it is not suppressed. The start time is in clock ticks, taken to be 100 per
+	// second, as USER_HZ (sysconf(_SC_CLK_TCK)) is on every architecture Go runs Linux on. Default is 0, which
+	// doesn't suppress.
+	MinProcessAge time.Duration
+	// StaleAfter, if set, is how old the last sample may be before PSS() stops trusting it, and samples anew
+	// (returning the stale value only if that fails). Something like twice the Interval is sensible, as
+	// anything older means the Limit goro is stuck, or stopped. Default is 0, which trusts it forever.
+	StaleAfter time.Duration
+	// OutlierFactor, if set, skips the kill decision (thresholds, limit, etc.) for any sample that is more than
+	// OutlierFactor times, or less than 1/OutlierFactor of, the median of the recent samples, such as the absurd
+	// values smaps can momentarily report during a large munmap. Skipped samples are still recorded (PSS,
+	// SampleChan, etc.) and logged. A real, sustained jump is acted on once it is the median, i.e. after half of
+	// the 32 recent samples. Should be greater than 1. Default is 0, which skips nothing.
+	OutlierFactor float64
+	// TickSource is an optional channel that, if set, is used in lieu of Interval to trigger each check.
+	TickSource <-chan time.Time
+	// Clock, if set, is used in lieu of the real clock for everything time-dependent, e.g. for deterministic
+	// tests. SampleLatency is always measured in real time, as it is the real cost of sampling.
+	Clock Clock
 
-	cancelled chan bool
-	nokill    bool        // Internal: true if the process should not be killed in overmemory cases
-	running   atomic.Bool // Internal: true if the Limit goro is running.
-	proc      *os.Process
-	limit     atomic.Int64
-	lastPss   atomic.Int64
-	limiter   func()
+	cancelled   chan bool
+	done        chan struct{} // Internal: closed when the Limit goro exits
+	nokill      bool          // Internal: true if the process should not be killed in overmemory cases
+	running     atomic.Bool   // Internal: true if the Limit goro is running.
+	proc        *os.Process
+	limit       atomic.Int64
+	delta       int64        // Internal: the growth allowed over baseline, if LimitRelative was used
+	baseline    atomic.Int64 // Internal: the first non-zero sample, if LimitRelative was used
+	confirmed   atomic.Bool  // Internal: true if the process was confirmed dead after a kill
+	lastPss     atomic.Int64
+	lastTime    atomic.Int64 // Internal: UnixNano of lastPss
+	latency     latency
+	overhead    overhead
+	thresholds  []threshold  // Internal: sorted by fraction
+	level       int          // Internal: the last threshold level fired
+	killed      atomic.Bool  // Internal: true once the limit has been breached and acted upon
+	suppressed  atomic.Int64 // Internal: count of kills suppressed by MinKillInterval
+	event       atomic.Pointer[KillEvent]
+	reportOnly  atomic.Bool               // Internal: true if we lost permission to kill
+	signaller   func(sig os.Signal) error // Internal: replaces Process.Signal, for testing
+	cfg         atomic.Pointer[config]    // Internal: snapshot of the config, taken by Limit
+	samples     hub[Sample]
+	statsSubs   hub[GuardStats] // Internal: Subscribe
+	pidfd       pidfd
+	manager     atomic.Pointer[Manager]
+	ctx         context.Context // Internal: from NewWithContext, or nil
+	pending     int64           // Internal: the limit from Configure, for Start
+	avg         window          // Internal: samples within AvgWindow
+	peak        atomic.Int64    // Internal: the highest sample
+	history     history         // Internal: recent samples, for BreachContext
+	accurate    history         // Internal: recent samples other than ConfirmWithPSS's RSS, for OutlierFactor
+	callbacks   callers         // Internal: the goros running callbacks
+	overWarn    atomic.Int64    // Internal: UnixNano since when continuously over WarnFraction, or 0
+	overLimit   atomic.Int64    // Internal: UnixNano since when continuously over the limit, or 0
+	stop        atomic.Int32    // Internal: the StopReason
+	checkLock   sync.Mutex      // Internal: serializes check
+	boost       atomic.Pointer[boost]
+	lowered     atomic.Pointer[lowered]
+	original    atomic.Int64
+	mappings    atomic.Int64
+	threads     atomic.Int64
+	threadsOver bool
+	children    atomic.Pointer[[]ProcessUsage]
+	cheap       atomic.Bool  // Internal: the last sample was the RSS of ConfirmWithPSS
+	keepPidfd   atomic.Bool  // Internal: CancelKill will close the pidfd after killing, not the Limit goro
+	crossings   crossings    // Internal: first crossing times, for ThresholdCrossings
+	outliers    atomic.Int64 // Internal: count of samples skipped by OutlierFactor
+	lifetime    lifetime     // Internal: every sample, for MeanPSS
+	rolling     rolling      // Internal: samples within GrowthWindow
+	overruns    int          // Internal: consecutive samples longer than Interval, only touched by the Limit goro
+	overrunning atomic.Bool
+	gauges      gauges       // Internal: from AddGauge
+	cgroup      string       // Internal: from NewCgroupGuard, or ""
+	state       atomic.Int32 // Internal: the last state of the process, for ProcessState
+	stops       int          // Internal: consecutive ticks the process was stopped, only touched by the Limit goro
+	limiter     func()
 }
 
-// New takes an os.Process and returns a MemoryGuard for that process
-func New(Process *os.Process) *MemoryGuard {
+// New takes an os.Process and optional Options, and returns a MemoryGuard for that process.
+// Options are applied in order, before the MemoryGuard is returned, so configuring with them
+// can't race with Limit().
+func New(Process *os.Process, opts ...Option) *MemoryGuard {
 	var mg = MemoryGuard{
 		proc:           Process,
 		Interval:       1 * time.Second,
 		KillChan:       make(chan struct{}),
+		ExitChan:       make(chan struct{}),
 		cancelled:      make(chan bool, 1),
+		done:           make(chan struct{}),
 		DebugOut:       log.New(io.Discard, "", 0),
 		ErrOut:         log.New(io.Discard, "", 0),
 		StatsFrequency: time.Minute,
+		ProcRoot:       DefaultProcRoot,
+		level:          -1,
 	}
 	mg.limiter = sync.OnceFunc(mg.onceLimit)
 
+	for _, opt := range opts {
+		opt(&mg)
+	}
+
 	return &mg
 }
 
 // PSS returns the last known PSS value for the watched process,
-// or the current value, if there was no last value. After a process is
+// or the current value, if there was no last value, or it is older than StaleAfter, in which case the current
+// value becomes the last known one, for another StaleAfter. After a process is
 // killed for going over, this will be the last value observed prior to
-// process death.
+// process death. If Metric is not MetricPSS, the value is of that Metric instead.
 func (m *MemoryGuard) PSS() int64 {
-	if lp := m.lastPss.Load(); lp > 0 {
+	lp := m.lastPss.Load()
+	if lp > 0 && !m.Stale() {
 		return lp
 	}
-	pss, err := getPss(m.proc.Pid)
+	pss, err := m.sample()
 	if err != nil {
-		return 0
+		// Stale is better than nothing
+		return lp
+	}
+	if m.conf().staleAfter > 0 {
+		// Rather than sampling again on every call until the Limit goro does
+		m.lastPss.Store(pss)
+		m.lastTime.Store(m.now().UnixNano())
+		if mgr := m.manager.Load(); mgr != nil {
+			mgr.sampled(m)
+		}
 	}
 	return pss
 }
 
+// LastSampleTime returns when the last known PSS value was sampled, or the zero Time if it never was.
+func (m *MemoryGuard) LastSampleTime() time.Time {
+	if t := m.lastTime.Load(); t != 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
+}
+
+// Stale returns true if StaleAfter is set, and the last known PSS value is older than it.
+func (m *MemoryGuard) Stale() bool {
+	after := m.conf().staleAfter
+	if after <= 0 {
+		return false
+	}
+	return m.now().Sub(m.LastSampleTime()) > after
+}
+
+// KillNotify returns a channel that will be closed if/when the process is killed, as KillChan, but always
+// the one for the current arming: after Reset, it returns the new KillChan.
+func (m *MemoryGuard) KillNotify() <-chan struct{} {
+	return m.conf().killChan
+}
+
+// Pid returns the PID of the watched process, or -1 if there is no process (e.g. NewCgroupGuard).
+func (m *MemoryGuard) Pid() int {
+	if m.proc == nil {
+		return -1
+	}
+	return m.proc.Pid
+}
+
+// Process returns the watched process, which may be nil. It is the caller's to Wait on, but
+// should not be Released while it is being guarded.
+func (m *MemoryGuard) Process() *os.Process {
+	return m.proc
+}
+
 // Cancel signals a Limit() operation to stop, returning immediately.
 // After calling Cancel this MemoryGuard will be non-functional
 func (m *MemoryGuard) Cancel() {
@@ -87,37 +462,200 @@ func (m *MemoryGuard) Cancel() {
 }
 
 // CancelWait signals a Limit() operation to stop, and waits to return until it is done.
-// After calling CancelWait this MemoryGuard will be non-functional
+// After calling CancelWait this MemoryGuard will be non-functional. It is safe to call from a callback
+// (OnSample, OnBreach, an Action, etc.), but then it can't wait, and is equivalent to Cancel: the Limit
+// goro stops once the callback returns.
 func (m *MemoryGuard) CancelWait() {
 
 	if !m.running.Load() {
 		// We are already stopped.
 		return
+	} else if m.callbacks.has(goid()) {
+		// We're in a callback, which the Limit goro may be running, or waiting on, so it can't stop until we return.
+		m.Cancel()
+		return
 	}
 
-	// Cancel, and poll until we're done.
+	// Cancel, and wait until we're done.
 	m.Cancel()
-	for {
-		if !m.running.Load() {
-			return
-		}
-		time.Sleep(time.Millisecond) // too aggressive?
+	<-m.done
+}
+
+// Close is equivalent to CancelWait (and releases any pidfd if OnDemand), and always returns nil. It allows a MemoryGuard to be used as an io.Closer.
+func (m *MemoryGuard) Close() error {
+	m.CancelWait()
+	m.pidfd.close()
+	return nil
+}
+
+// Reset returns a stopped MemoryGuard to its state just after New, keeping its configuration (fields, Options,
+// and AddThreshold Actions) and process, so that Limit() may be called again, e.g. to reuse it across subtests.
+// Everything observed (samples, KillEvent, etc.) is forgotten, and KillChan and Done() are new channels.
+// It must not be called concurrently with any other method. Returns an error if the Limit goro is running.
+func (m *MemoryGuard) Reset() error {
+	if m.running.Load() {
+		return ResetRunningError
 	}
 
+	m.pidfd.close()
+	m.KillChan = make(chan struct{})
+	m.ExitChan = make(chan struct{})
+	m.KillError = nil
+	m.cancelled = make(chan bool, 1)
+	m.done = make(chan struct{})
+	m.limiter = sync.OnceFunc(m.onceLimit)
+	m.limit.Store(0)
+	m.delta = 0
+	m.baseline.Store(0)
+	m.confirmed.Store(false)
+	m.lastPss.Store(0)
+	m.lastTime.Store(0)
+	if mgr := m.manager.Load(); mgr != nil {
+		mgr.sampled(m)
+	}
+	m.latency = latency{}
+	m.overhead = overhead{}
+	m.thresholds = slices.DeleteFunc(m.thresholds, func(t threshold) bool { return t.internal })
+	m.level = -1
+	m.killed.Store(false)
+	m.suppressed.Store(0)
+	m.event.Store(nil)
+	m.reportOnly.Store(false)
+	m.cfg.Store(nil)
+	m.samples = hub[Sample]{}
+	m.statsSubs = hub[GuardStats]{}
+	m.stop.Store(int32(ReasonNone))
+	m.avg = window{}
+	m.peak.Store(0)
+	m.history = history{}
+	m.accurate = history{}
+	m.overWarn.Store(0)
+	m.overLimit.Store(0)
+	m.boost.Store(nil)
+	m.lowered.Store(nil)
+	m.original.Store(0)
+	m.mappings.Store(0)
+	m.threads.Store(0)
+	m.threadsOver = false
+	m.cheap.Store(false)
+	m.children.Store(nil)
+	m.crossings.reset()
+	m.outliers.Store(0)
+	m.lifetime = lifetime{}
+	m.rolling = rolling{}
+	m.overruns = 0
+	m.overrunning.Store(false)
+	m.state.Store(0)
+	m.stops = 0
+	return nil
+}
+
+// Done returns a channel that is closed when the Limit() goro exits, for any reason
+// (cancellation, kill, etc.). If Limit() is never successfully called, the channel
+// is never closed.
+func (m *MemoryGuard) Done() <-chan struct{} {
+	return m.done
 }
 
 // Limit takes the max usage (in Bytes) for the process and acts on the PSS.
 // Returns an error if Limit is called with a zero or negative value,
 // with a nil Process reference (did you use New()?),
+// if the procfs at ProcRoot is unavailable (unless the Metric is MetricMaxRSS),
+// if the Metric is MetricMaxRSS and the process isn't us,
+// if MaxMappings is set with a Metric that doesn't count mappings,
 // or if it has already been called once before, successfully.
+// A max that can never be reached (see SkipLimitCheck) is logged as a warning, not an error.
 func (m *MemoryGuard) Limit(max int64) error {
 	if max <= 0 {
 		return LimitZeroError
-	} else if m.proc == nil {
+	}
+	return m.arm(max, 0)
+}
+
+// Configure takes the max usage (in Bytes) and optional Options, and returns a MemoryGuard without a process,
+// so that policy can be decided before the process exists. Call Start with the process to begin guarding.
+func Configure(max int64, opts ...Option) *MemoryGuard {
+	m := New(nil, opts...)
+	m.pending = max
+	return m
+}
+
+// Start attaches the process to a MemoryGuard returned by Configure, and begins guarding it as if Limit had
+// been called with the configured max. Returns the same errors as Limit.
+func (m *MemoryGuard) Start(Process *os.Process) error {
+	if m.pending <= 0 {
+		return LimitZeroError
+	} else if m.limit.Load() != 0 {
+		return LimitOnceError
+	}
+	m.proc = Process
+	return m.Limit(m.pending)
+}
+
+// NewMonitor takes an os.Process, an interval, a function to call with every sample (see OnSample), and
+// optional Options, and returns a running MemoryGuard that samples the process but has no limit, and
+// will never kill it. Cancel it when done. Returns the same errors as Limit, other than for the limit.
+func NewMonitor(Process *os.Process, interval time.Duration, onSample func(Sample), opts ...Option) (*MemoryGuard, error) {
+	m := New(Process, append(opts, WithInterval(interval), WithOnSample(onSample))...)
+	if err := m.arm(monitorLimit, 0); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LimitRelative takes the max growth (in Bytes) for the process over its baseline,
+// which is the first non-zero PSS sample, and acts on the PSS. Useful for leak detection, where
+// the legitimate startup footprint of the process is unknown or uninteresting.
+// Returns the same errors as Limit.
+func (m *MemoryGuard) LimitRelative(delta int64) error {
+	if delta <= 0 {
+		return LimitZeroError
+	}
+	return m.arm(unknownLimit, delta)
+}
+
+// arm validates the MemoryGuard, sets the limit, snapshots the config, and starts the Limit goro unless OnDemand.
+func (m *MemoryGuard) arm(max, delta int64) error {
+	c := m.snapshot()
+	if m.proc == nil && m.cgroup == "" {
 		return LimitNilProcessError
+	} else if c.simulate == nil && c.metric != MetricMaxRSS && !procfsAvailable(c.procRoot) {
+		return ProcfsUnavailableError
+	} else if c.metric == MetricMaxRSS && c.simulate == nil && m.Pid() != os.Getpid() {
+		return MaxRSSSelfError
+	} else if c.maxMappings > 0 && (c.metric == MetricRSSFast || c.metric == MetricMaxRSS || m.cgroup != "") {
+		return MaxMappingsMetricError
 	} else if !m.limit.CompareAndSwap(0, max) {
 		return LimitOnceError
 	}
+	m.delta = delta
+	if c.name == "" && m.cgroup == "" {
+		c.name = deriveName(c.procRoot, m.proc.Pid, m.NameFrom)
+	}
+	if m.cgroup == "" {
+		// It rarely changes, and may not be readable once it's gone
+		c.cmdline = readProcString(c.procRoot, m.proc.Pid, "cmdline")
+	}
+	m.cfg.Store(c)
+	if !c.skipLimitCheck && max > 0 && max < monitorLimit {
+		m.checkReachable(c, max)
+	}
+	if c.usePidfd && c.simulate == nil && m.cgroup == "" {
+		if err := m.pidfd.openPidfd(m.proc.Pid); err != nil {
+			// Old kernel, or the process is already gone: we'll fall back to Process.Signal
+			m.logf(LevelDebug, []any{"name", c.name, "error", err}, "[%s] MemoryGuard pidfd Error: %s\n", c.name, err)
+		}
+	}
+	if c.confirmWithPSS && (!c.confirmsWithPSS() || m.cgroup != "") {
+		m.logf(LevelDebug, []any{"name", c.name, "metric", c.metric}, "[%s] MemoryGuard: ConfirmWithPSS is ignored for %s, or a cgroup\n", c.name, c.metric)
+	}
+	if c.onDrain != nil && c.drainFraction > 0 {
+		m.addThreshold(threshold{fraction: c.drainFraction, action: func(int64, int64) { c.onDrain() }, internal: true})
+	}
+	if c.onDemand {
+		// CheckNow is the caller's responsibility
+		return nil
+	}
 	m.running.Store(true)
 
 	go m.limiter()
@@ -125,98 +663,613 @@ func (m *MemoryGuard) Limit(max int64) error {
 	return nil
 }
 
+// Baseline returns the baseline PSS captured when LimitRelative is used, or 0 if it
+// is not used, or has not yet been captured.
+func (m *MemoryGuard) Baseline() int64 {
+	return m.baseline.Load()
+}
+
+// EffectiveLimit returns the limit currently being enforced, per any LimitSchedule, and including any active BoostLimit,
+// or 0 if Limit has not been called, LimitRelative has not yet captured a baseline, or this is a monitor.
+func (m *MemoryGuard) EffectiveLimit() int64 {
+	if l := m.ScheduledLimit(); l > 0 {
+		return l + m.activeBoost()
+	}
+	return 0
+}
+
+// Headroom returns how far the last sample is under the EffectiveLimit, in Bytes, or 0 if it is over,
+// or there is no EffectiveLimit.
+func (m *MemoryGuard) Headroom() int64 {
+	if l := m.EffectiveLimit(); l > 0 {
+		return max(l-m.lastPss.Load(), 0)
+	}
+	return 0
+}
+
+// Overage returns how far the last sample is over the EffectiveLimit, in Bytes, or 0 if it is under,
+// or there is no EffectiveLimit.
+func (m *MemoryGuard) Overage() int64 {
+	if l := m.EffectiveLimit(); l > 0 {
+		return max(m.lastPss.Load()-l, 0)
+	}
+	return 0
+}
+
+// OverLimit returns true if the last sample is over the EffectiveLimit, whether or not anything was done about
+// it (e.g. ReportOnly, MinProcessAge, or a declining OnBreach), making it the instantaneous state, unlike WasKilled.
+// It is false if there is no EffectiveLimit.
+func (m *MemoryGuard) OverLimit() bool {
+	return m.Overage() > 0
+}
+
+// HeadroomFraction returns Headroom as a fraction of the EffectiveLimit, from 1 (nothing used) to 0 (at or
+// over the limit), or 0 if there is no EffectiveLimit.
+func (m *MemoryGuard) HeadroomFraction() float64 {
+	if l := m.EffectiveLimit(); l > 0 {
+		return float64(max(l-m.lastPss.Load(), 0)) / float64(l)
+	}
+	return 0
+}
+
 func (m *MemoryGuard) onceLimit() {
 	defer func() {
-		m.DebugOut.Print("MemoryGuard Limiter Leaving!\n")
+		m.logf(LevelDebug, nil, "MemoryGuard Limiter Leaving!\n")
+		if c := m.conf(); c.killChanOnStop && !m.killed.Load() {
+			// If we killed, it's already closed
+			close(c.killChan)
+		}
 		m.running.Store(false)
+		m.samples.close()
+		m.statsSubs.close()
+		if !m.keepPidfd.Load() {
+			m.pidfd.close()
+		}
+		close(m.done)
 	}()
 
 	var (
-		name   = m.Name
-		max    = m.limit.Load() // it should be impossible for this to be <= 0.
+		c      = m.conf()
+		name   = c.name
 		errors int
+		seen   bool // true once the process has been sampled, so it can be said to have exited
 	)
-	if name == "" {
-		name = fmt.Sprintf("%d", m.proc.Pid) // if proc hasn't been assigned, we panic here.
+	if c.profileLabels {
+		// This goro is ours alone, so label it for pprof goroutine dumps.
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("memoryguard", name, "pid", strconv.Itoa(m.Pid()))))
+	}
+	m.logf(LevelDebug, []any{"name", name, "limit", m.EffectiveLimit(), "interval", c.interval}, "[%s] MemoryGuard Running! Limit %d Interval %s\n", name, m.EffectiveLimit(), c.interval)
+
+	var stats <-chan time.Time
+	if c.statsFrequency > 0 {
+		stats = c.clock.After(c.statsFrequency)
+	}
+
+	var ctxDone <-chan struct{}
+	if m.ctx != nil {
+		ctxDone = m.ctx.Done()
 	}
-	m.DebugOut.Printf("[%s] MemoryGuard Running! %v\n", name, m)
 
-	since := time.Now()
+	tick := c.ticker()
 	for {
+		var (
+			xss int64
+			err error
+		)
 		select {
 		case <-m.cancelled:
-			m.DebugOut.Printf("[%s] MemoryGuard Cancelled!\n", name)
+			m.logf(LevelDebug, []any{"name", name}, "[%s] MemoryGuard Cancelled!\n", name)
+			m.stop.Store(int32(ReasonCancel))
 			return
-		case <-time.After(m.Interval):
+		case <-ctxDone:
+			m.contextDone(name)
+			return
+		case <-stats:
+			// Belch out the stats every so often
+			stats = c.clock.After(c.statsFrequency)
+			m.emitStats(name, c, errors)
+			continue
+		case <-tick:
+			if d := m.stopped(name, c); d > 0 {
+				// Nothing to see
+				if tick = c.tick; tick == nil {
+					tick = c.clock.After(d)
+				}
+				continue
+			}
 			// Go for it
+			tick = c.ticker()
+			xss, err = m.timedSample()
+			if d := m.overran(name, m.latency.summary().Last); d > 0 {
+				tick = c.clock.After(d)
+			}
+		case xss = <-c.simulate:
+			// Simulated sample
 		}
 
-		var (
-			xss int64
-			err error
-		)
-
-		xss, err = getPss(m.proc.Pid)
-		if err != nil {
+		if (err != nil || xss == 0) && c.simulate == nil && m.cgroup == "" && isZombie(c.procRoot, m.proc.Pid) {
+			// Its smaps is empty or unreadable, and signalling it is pointless.
+			m.logf(LevelError, []any{"name", name}, "[%s] MemoryGuard process is a zombie, stopping\n", name)
+			m.exited(ReasonZombie)
+			return
+		} else if err != nil && seen && c.simulate == nil && m.reaped(c) {
+			// It's gone entirely, and we didn't do it.
+			m.logf(LevelError, []any{"name", name}, "[%s] MemoryGuard process has exited, stopping\n", name)
+			m.exited(ReasonProcessExited)
+			return
+		} else if err != nil {
 			errors++
-			m.ErrOut.Printf("[%s] MemoryGuard getPss Error: %s (%d)\n", name, err, errors)
+			m.logf(LevelError, []any{"name", name, "error", err, "errors", errors}, "[%s] MemoryGuard getPss Error: %s (%d)\n", name, err, errors)
 			continue
-		} else {
-			errors = 0 //reset
-			m.lastPss.Store(xss)
-		}
-
-		if xss > max {
-			m.ErrOut.Printf("[%s] MemoryGuard ALERT! %s Limit %s\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max))
-			close(m.KillChan)
-			if m.nokill {
-				// don't kill it
-			} else {
-				// kill it
-				m.KillError = m.proc.Kill()
+		} else if errors > 0 {
+			m.logf(LevelDebug, []any{"name", name, "errors", errors}, "[%s] MemoryGuard recovered after %d errors\n", name, errors)
+			if f := c.onRecover; f != nil {
+				after := errors
+				m.callback(func() { f(after) })
+			}
+		}
+		errors = 0 //reset
+		seen = true
+		m.storePss(xss)
+		if c.systemdWatchdog {
+			if err := sdNotify("WATCHDOG=1"); err != nil {
+				m.logf(LevelDebug, []any{"name", name, "error", err}, "[%s] MemoryGuard systemd watchdog Error: %s\n", name, err)
 			}
+		}
+
+		m.checkLock.Lock()
+		over := m.check(name, xss)
+		m.checkLock.Unlock()
+		m.samples.publish(Sample{Time: c.clock.Now(), Value: xss, Limit: m.EffectiveLimit()}, c.sampleDropOldest)
+		if over && m.killed.Load() {
+			m.stop.Store(int32(ReasonKill))
 			m.running.Store(false)
 			return
-		} else if time.Since(since) >= m.StatsFrequency {
-			// Belch out the stats every so often
-			since = time.Now()
-			m.DebugOut.Printf("[%s] MemoryGuard: %s Limit %s Consecutive errors: %d\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max), errors)
 		}
 	}
 }
 
-// getPss takes a pid, and returns the sum of PSS page sizes in Bytes, or an error
-//
-// Benchmark_getpss-12        	    2278	    490040 ns/op	   13039 B/op	     382 allocs/op
-// Benchmark_getpss2-12       	    2190	    524059 ns/op	   84773 B/op	    2543 allocs/op
-// Benchmark_getUtilPss-12    	    1279	   1179068 ns/op	  681705 B/op	    4535 allocs/op
-func getPss(pid int) (int64, error) {
-	f, err := os.Open(fmt.Sprintf("/proc/%d/smaps", pid))
+// CheckNow synchronously samples the process, compares it against the limit, and if it is over,
+// kills it (or whatever else is configured), returning the sample and whether it was over.
+// Limit() or LimitRelative() must have been called first, and is best used with OnDemand set so no
+// Limit goro is also checking, though it is safe either way.
+func (m *MemoryGuard) CheckNow() (pss int64, overLimit bool, err error) {
+	if m.limit.Load() == 0 {
+		return 0, false, LimitNotSetError
+	}
+
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+
+	pss, err = m.timedSample()
+	if err != nil {
+		return 0, false, err
+	}
+	m.storePss(pss)
+
+	return pss, m.check(m.name(), pss), nil
+}
+
+// ForceSample samples the process now, records it as the last known value (for PSS(), PeakPSS(), etc.) just
+// as the Limit goro would, and returns it, or an error. Unlike CheckNow, it doesn't compare it against the limit,
+// and it may be called whether or not Limit() has been.
+func (m *MemoryGuard) ForceSample() (int64, error) {
+	if m.proc == nil && m.cgroup == "" {
+		return 0, LimitNilProcessError
+	}
+
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+
+	pss, err := m.timedSample()
 	if err != nil {
 		return 0, err
 	}
-	defer f.Close()
+	m.storePss(pss)
+	return pss, nil
+}
+
+// WaitUntilBelow samples the process (as ForceSample) every Interval until it is below xss, returning nil,
+// or until timeout passes, returning WaitTimeoutError. Sampling errors are returned immediately. Useful after
+// asking the process to shed memory, to wait for it to recover before proceeding.
+func (m *MemoryGuard) WaitUntilBelow(xss int64, timeout time.Duration) error {
+	c := m.conf()
+	deadline := c.clock.Now().Add(timeout)
+	for {
+		pss, err := m.ForceSample()
+		if err != nil {
+			return err
+		} else if pss < xss {
+			return nil
+		} else if !c.clock.Now().Before(deadline) {
+			return WaitTimeoutError
+		}
+		sleep(c.clock, min(c.interval, deadline.Sub(c.clock.Now())))
+	}
+}
+
+// exited records the reason the process exited on its own, closes ExitChan, and calls OnExit.
+func (m *MemoryGuard) exited(reason StopReason) {
+	c := m.conf()
+	m.stop.Store(int32(reason))
+	close(c.exitChan)
+	if f := c.onExit; f != nil {
+		m.callback(func() { f(m.Pid()) })
+	}
+}
+
+// callback calls f, which calls a user callback, noting the goro it is running on so CancelWait from it won't deadlock.
+func (m *MemoryGuard) callback(f func()) {
+	id := goid()
+	m.callbacks.enter(id)
+	defer m.callbacks.leave(id)
+	f()
+}
+
+// callers counts the callbacks running on each goro, by goroutine id.
+type callers struct {
+	lock sync.Mutex
+	ids  map[uint64]int
+}
+
+// enter notes a callback starting on the goro id.
+func (c *callers) enter(id uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ids == nil {
+		c.ids = make(map[uint64]int)
+	}
+	c.ids[id]++
+}
+
+// leave notes a callback finishing on the goro id.
+func (c *callers) leave(id uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ids[id]--; c.ids[id] <= 0 {
+		delete(c.ids, id)
+	}
+}
+
+// has returns true if a callback is running on the goro id.
+func (c *callers) has(id uint64) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ids[id] > 0
+}
+
+// goid returns the id of the calling goro, from the "goroutine N [...]" header of its stack, or 0.
+func goid() uint64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// storePss records the latest sample in lastPss, peak, and history, and lets our Manager, if any, know about it.
+func (m *MemoryGuard) storePss(xss int64) {
+	m.lastPss.Store(xss)
+	m.lastTime.Store(m.now().UnixNano())
+	m.lifetime.add(xss)
+	s := Sample{Time: m.now(), Value: xss, Limit: m.EffectiveLimit()}
+	m.history.add(s)
+	if !m.cheap.Load() {
+		m.accurate.add(s)
+	}
+	for {
+		peak := m.peak.Load()
+		if xss <= peak || m.peak.CompareAndSwap(peak, xss) {
+			break
+		}
+	}
+	if mgr := m.manager.Load(); mgr != nil {
+		mgr.sampled(m)
+	}
+}
+
+// check evaluates a successful sample against the baseline, thresholds, and limit,
+// acting on each as needed. Returns true if the limit was breached, whether or not the process was killed.
+// Callers must hold checkLock.
+func (m *MemoryGuard) check(name string, xss int64) bool {
+	max := m.limit.Load() // it should be impossible for this to be <= 0.
+
+	if f := m.conf().onSample; f != nil {
+		m.callback(func() { f(Sample{Time: m.now(), Value: xss, Limit: m.EffectiveLimit()}) })
+	}
+	cheap := m.cheap.Load()
+	if !cheap && m.outlier(name, xss) {
+		return false
+	}
+	if span := m.conf().avgWindow; span > 0 && !cheap {
+		// Decide on the average, not the sample
+		xss = m.avg.add(m.now(), xss, span)
+	}
+
+	if max == monitorLimit {
+		// Just watching
+		return false
+	} else if max == unknownLimit && xss > 0 {
+		// First sample for LimitRelative, set our baseline.
+		m.baseline.Store(xss)
+		max = xss + m.delta
+		m.limit.Store(max)
+		m.logf(LevelDebug, []any{"name", name, "baseline", xss, "limit", max}, "[%s] MemoryGuard Baseline: %s Limit %s\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max))
+	}
+
+	if max != unknownLimit {
+		// Until LimitRelative has a baseline, there is nothing to adjust, and adding to it would overflow
+		max = m.scheduled(m.graced(max)) + m.activeBoost()
+	}
+
+	m.trackOver(xss, max)
+	if l := m.crossedThreshold(xss, max); l != m.level {
+		if l > m.level {
+			m.recordCrossings(name, l, xss, max)
+			m.logf(LevelDebug, []any{"name", name, "threshold", m.thresholds[l].fraction, "pss", xss, "limit", max}, "[%s] MemoryGuard Threshold %.2f crossed: %s Limit %s\n", name, m.thresholds[l].fraction, humanity.ByteFormat(xss), humanity.ByteFormat(max))
+			m.callback(func() { m.thresholds[l].action(xss, max) })
+		}
+		m.level = l
+	}
 
 	var (
-		res int64
-		pfx = []byte("Pss:")
+		trigger TriggerType
+		over    = xss > max
+		metrics []Metric
 	)
+	if len(m.conf().compositeMetrics) > 0 {
+		over, metrics = m.compositeOver(name, xss, max)
+	} else if f := m.conf().shouldKill; f != nil {
+		history := m.history.samples()
+		m.callback(func() { over = f(xss, max, history) })
+	}
+	psi := m.conf().psiThreshold > 0 && m.psiExceeded()
+	if over && m.conf().psiCombined && !psi {
+		// Both or nothing, for the limit
+	} else if over {
+		trigger = TriggerLimit
+		if m.delta > 0 && m.original.Load() == 0 {
+			// Still the limit LimitRelative computed, not one from SetLimit
+			trigger = TriggerRelativeLimit
+		}
+	}
+	if cheap {
+		// The RSS of ConfirmWithPSS isn't comparable with the Metric
+	} else if limit, grown := m.grown(name, xss); trigger == TriggerNone && grown {
+		trigger = TriggerGrowth
+		max = limit
+	}
+	if trigger == TriggerNone && !cheap && m.tooManyMappings() {
+		trigger = TriggerMappings
+	}
+	if trigger == TriggerNone && m.tooManyThreads(name) {
+		trigger = TriggerThreads
+	}
+	if trigger == TriggerNone && psi && !m.conf().psiCombined {
+		trigger = TriggerPSI
+	}
 
-	r := bufio.NewScanner(f)
-	for r.Scan() {
-		line := r.Bytes()
-		if bytes.HasPrefix(line, pfx) {
-			var size int64
-			_, err := fmt.Sscanf(string(line[4:]), "%d", &size)
-			if err != nil {
-				return 0, err
-			}
-			res += size
+	if trigger == TriggerNone {
+		return false
+	} else if m.killed.Load() {
+		// Already handled
+		return true
+	} else if m.reportOnly.Load() {
+		m.logf(LevelError, []any{"name", name, "pss", xss, "limit", max, "trigger", trigger}, "[%s] MemoryGuard ALERT! %s Limit %s (%s), report-only\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max), trigger)
+		return true
+	} else if pressure, avail := m.underPressure(); !pressure {
+		m.logf(LevelDebug, []any{"name", name, "pss", xss, "limit", max, "available", avail}, "[%s] MemoryGuard: %s Limit %s, but system has %s available, not killing\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max), humanity.ByteFormat(avail))
+		return true
+	} else if age, young := m.tooYoung(); young {
+		m.logf(LevelDebug, []any{"name", name, "pss", xss, "limit", max, "age", age}, "[%s] MemoryGuard: %s Limit %s, but the process is only %s old, not killing\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max), age)
+		return true
+	} else if last, ok := recentKill(name, m.conf().minKillInterval, m.now()); ok {
+		m.suppressed.Add(1)
+		m.logf(LevelError, []any{"name", name, "pss", xss, "limit", max, "lastkill", last}, "[%s] MemoryGuard ALERT! %s Limit %s, but kill suppressed: last kill was %s ago\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max), m.now().Sub(last))
+		return true
+	} else if trigger != TriggerPSI && m.quiesced(name, max) {
+		// It was garbage
+		return false
+	} else if !m.killed.CompareAndSwap(false, true) {
+		// Already handled
+		return true
+	}
+
+	m.breach(name, trigger, xss, max, metrics)
+	return true
+}
+
+// breach acts on the process for the trigger, records the KillEvent, and closes KillChan.
+// If the kill is denied for lack of permission, the MemoryGuard degrades to ReportOnly instead,
+// and KillChan is not closed. metrics are those over the limit, if CompositeMetrics are set. Must only be called once.
+func (m *MemoryGuard) breach(name string, trigger TriggerType, xss, max int64, metrics []Metric) {
+	var cmdline string
+	if c := m.conf().cmdline; c != "" {
+		cmdline = ": " + c
+	}
+	m.logf(LevelError, []any{"name", name, "pss", xss, "limit", max, "trigger", trigger, "cmdline", m.conf().cmdline}, "[%s] MemoryGuard ALERT! %s Limit %s (%s)%s\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max), trigger, cmdline)
+	event := KillEvent{
+		Trigger: trigger,
+		Time:    m.now(),
+		Sample:  xss,
+		Limit:   max,
+		Metrics: metrics,
+		Gauges:  m.gaugeMap(),
+		Labels:  maps.Clone(m.conf().labels),
+		Cmdline: m.conf().cmdline,
+	}
+	if n := m.conf().reportTopMappings; n > 0 && m.conf().simulate == nil && m.cgroup == "" {
+		// Must be before the kill, or there will be nothing to read
+		top, err := topMappings(m.procRoot(), m.proc.Pid, n)
+		if err != nil {
+			m.logf(LevelError, []any{"name", name, "error", err}, "[%s] MemoryGuard smaps Error: %s\n", name, err)
+		}
+		event.TopMappings = top
+		for i, mp := range top {
+			m.logf(LevelError, []any{"name", name, "rank", i + 1, "address", mp.Address, "pathname", mp.Pathname, "pss", mp.Pss}, "[%s] MemoryGuard Top Mapping %d: %s %s %s\n", name, i+1, mp.Address, mp.Pathname, humanity.ByteFormat(mp.Pss))
 		}
 	}
-	if err := r.Err(); err != nil {
-		return 0, err
+	if top := m.topChild(); top != nil {
+		// From the last sample, but the cmdline must be read before the kill
+		top.Cmdline = readProcString(m.procRoot(), top.Pid, "cmdline")
+		event.TopChild = top
+		m.logf(LevelError, []any{"name", name, "pid", top.Pid, "cmdline", top.Cmdline, "pss", top.PSS}, "[%s] MemoryGuard Top Child: %d %s %s\n", name, top.Pid, top.Cmdline, humanity.ByteFormat(top.PSS))
+	}
+	if f := m.conf().onBreach; f != nil {
+		// it's their call
+		bc := BreachContext{
+			Name:    name,
+			Trigger: trigger,
+			Time:    event.Time,
+			Sample:  xss,
+			Peak:    m.peak.Load(),
+			Limit:   max,
+			History: m.history.samples(),
+			Metrics: metrics,
+			Gauges:  event.Gauges,
+			Labels:  event.Labels,
+			m:       m,
+		}
+		var act bool
+		m.callback(func() { act = f(&bc) })
+		if !act {
+			m.killed.Store(false)
+			m.logf(LevelDebug, []any{"name", name, "pss", xss, "limit", max, "trigger", trigger}, "[%s] MemoryGuard: OnBreach declined to act\n", name)
+			return
+		}
+	} else {
+		m.KillError = m.act()
+	}
+	event.Confirmed = m.confirmed.Load()
+	event.Error = m.KillError
+	m.event.Store(&event)
+
+	if errors.Is(m.KillError, os.ErrPermission) {
+		// We can't kill it, and never will be able to, so degrade to reporting.
+		m.reportOnly.Store(true)
+		m.killed.Store(false)
+		m.logf(LevelError, []any{"name", name, "error", m.KillError}, "[%s] MemoryGuard ESCALATION! Permission denied killing the process, degrading to report-only: %s\n", name, m.KillError)
+		if f := m.conf().onKillDenied; f != nil {
+			m.callback(func() { f(&event) })
+		}
+		return
+	}
+
+	recordKill(name, m.conf().minKillInterval, m.now())
+	close(m.conf().killChan)
+}
+
+// timedSample calls sample, recording its latency, and enforcing SampleTimeout if set.
+func (m *MemoryGuard) timedSample() (int64, error) {
+	start := time.Now()
+	defer func() {
+		m.latency.record(time.Since(start))
+	}()
+
+	timeout := m.conf().sampleTimeout
+	if timeout <= 0 {
+		return m.sample()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type result struct {
+		xss int64
+		err error
+	}
+	rchan := make(chan result, 1) // buffered so an abandoned sample can still finish
+	go func() {
+		xss, err := m.sample()
+		rchan <- result{xss, err}
+	}()
+
+	select {
+	case r := <-rchan:
+		return r.xss, r.err
+	case <-ctx.Done():
+		return 0, SampleTimeoutError
+	}
+}
+
+// name returns the Name, or the PID if Name is unset.
+func (m *MemoryGuard) name() string {
+	if c := m.cfg.Load(); c != nil {
+		return c.name
+	} else if m.Name != "" || m.proc == nil {
+		return m.Name
+	}
+	return deriveName(m.procRoot(), m.proc.Pid, m.NameFrom)
+}
+
+// procRoot returns the ProcRoot, or DefaultProcRoot if it is unset.
+func (m *MemoryGuard) procRoot() string {
+	if c := m.cfg.Load(); c != nil {
+		return c.procRoot
+	} else if m.ProcRoot != "" {
+		return m.ProcRoot
+	}
+	return DefaultProcRoot
+}
+
+// procfsAvailable returns true if root looks like a mounted procfs.
+func procfsAvailable(root string) bool {
+	_, err := os.Stat(filepath.Join(root, "stat"))
+	return err == nil
+}
+
+// procPath returns the path to the named file for pid under the procfs root.
+func procPath(root string, pid int, file string) string {
+	return filepath.Join(root, strconv.Itoa(pid), file)
+}
+
+// ticker returns the TickSource if set, or a new channel that will fire after Interval, plus a random
+// IntervalJitter if set. If SimulateChan is set, returns nil so we never tick.
+func (c *config) ticker() <-chan time.Time {
+	if c.simulate != nil {
+		return nil
+	} else if c.tick != nil {
+		return c.tick
 	}
+	return c.clock.After(c.nextInterval())
+}
+
+// nextInterval returns Interval, plus a random duration in [0, IntervalJitter) if it is set.
+func (c *config) nextInterval() time.Duration {
+	if c.intervalJitter <= 0 {
+		return c.interval
+	}
+	if c.rng == nil {
+		// Only the Limit goro uses it, so it needn't be goro-safe
+		c.rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	return c.interval + time.Duration(c.rng.Int64N(int64(c.intervalJitter)))
+}
+
+// getPss takes a procfs root and a pid, and returns the sum of PSS page sizes in Bytes, or an error
+//
+// Benchmark_getpss           	    8572	    153962 ns/op	    4253 B/op	       6 allocs/op
+// Benchmark_getpss2          	    5810	    232495 ns/op	   57699 B/op	    1702 allocs/op
+// Benchmark_getUtilPss       	    2482	    493271 ns/op	  464197 B/op	    3049 allocs/op
+func getPss(root string, pid int) (int64, error) {
+	info, err := getPssInfo(root, pid)
+	return info.Bytes, err
+}
 
-	return res * 1024, nil
+// getPssInfo is getPss, returning a SampleInfo.
+func getPssInfo(root string, pid int) (SampleInfo, error) {
+	path := procPath(root, pid, "smaps")
+	f, err := os.Open(path)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	defer f.Close()
+
+	b, n, err := parsePss(f)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	return SampleInfo{Bytes: b, Source: path, MappingCount: n}, nil
 }
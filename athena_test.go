@@ -3,9 +3,15 @@ package memoryguard
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime/pprof"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -99,6 +105,18 @@ func Test_MemoryGuardLimitZero(t *testing.T) {
 	})
 }
 
+func Test_MemoryGuardNoProcfs(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is created with a ProcRoot that isn't a procfs, it refuses", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.ProcRoot = t.TempDir()
+		So(mg.Limit(400*1024*1024), ShouldEqual, ProcfsUnavailableError)
+		So(mg.running.Load(), ShouldBeFalse)
+	})
+}
+
 func Test_MemoryGuardOnUsDelay(t *testing.T) {
 	defer leaktest.Check(t)()
 
@@ -106,14 +124,17 @@ func Test_MemoryGuardOnUsDelay(t *testing.T) {
 		var limit = int64(400 * 1024 * 1024)
 
 		us, _ := os.FindProcess(os.Getpid())
-		mg := New(us)
-		mg.StatsFrequency = time.Second
+		mg := New(us, WithInterval(10*time.Millisecond))
+		mg.StatsFrequency = 20 * time.Millisecond
+		samples := mg.Samples()
 		mg.Limit(limit) // we won't actually hit this, right?
 		// deliberately NOT defering a cancel, because we are going to CancelWait later.
 		//defer mg.Cancel()
 
-		Convey("After 2 seconds", func() {
-			time.Sleep(2 * time.Second)
+		Convey("After a few samples, and stats", func() {
+			for range 3 {
+				<-samples
+			}
 
 			Convey("we don't get killed, and a PSS is returned", func() {
 				So(mg.running.Load(), ShouldBeTrue)
@@ -133,7 +154,7 @@ func Test_MemoryGuardSmapsBadPid(t *testing.T) {
 	defer leaktest.Check(t)()
 
 	Convey("When a MemoryGuard checks SMAPS for an invalid pid", t, func() {
-		_, e := getPss(-10)
+		_, e := getPss(DefaultProcRoot, -10)
 		Convey("it returns an error", func() {
 			So(e, ShouldNotBeNil)
 		})
@@ -144,7 +165,7 @@ func Test_MemoryGuardGetPss(t *testing.T) {
 	defer leaktest.Check(t)()
 
 	Convey("When a MemoryGuard checks SMAPS with a valid pid for PSS", t, func() {
-		_, e := getPss(os.Getpid())
+		_, e := getPss(DefaultProcRoot, os.Getpid())
 		Convey("it doesn't return an error", func() {
 			So(e, ShouldBeNil)
 		})
@@ -245,6 +266,276 @@ func Test_MemoryGuardKillPSS(t *testing.T) {
 	})
 }
 
+func Test_MemoryGuardTickSource(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with a TickSource", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us)
+		mg.Interval = time.Hour // we should never wait on this
+		mg.TickSource = tick
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		mg.Limit(1024)   // 1KB
+
+		Convey("nothing happens until we tick, and then we get killed", func() {
+			defer mg.Cancel()
+			So(mg.running.Load(), ShouldBeTrue)
+			So(mg.lastPss.Load(), ShouldEqual, 0)
+
+			tick <- time.Now()
+			<-mg.KillChan // wait for the kill
+			So(mg.lastPss.Load(), ShouldBeGreaterThan, 1024)
+		})
+	})
+}
+
+func Test_MemoryGuardClose(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		var mg io.Closer = New(us)
+		mg.(*MemoryGuard).Limit(400 * 1024 * 1024) // we won't actually hit this, right?
+
+		Convey("and we Close it as an io.Closer, it stops", func() {
+			So(mg.Close(), ShouldBeNil)
+			So(mg.(*MemoryGuard).running.Load(), ShouldBeFalse)
+		})
+	})
+}
+
+func Test_MemoryGuardProfileLabels(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with ProfileLabels", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us)
+		mg.Name = "labelled"
+		mg.ProfileLabels = true
+		mg.TickSource = tick
+		mg.Limit(400 * 1024 * 1024) // we won't actually hit this, right?
+		defer mg.Cancel()
+
+		Convey("the goroutine dump shows the label", func() {
+			tick <- time.Now() // ensure the goro is up and labelled
+
+			var b strings.Builder
+			pprof.Lookup("goroutine").WriteTo(&b, 1)
+			So(b.String(), ShouldContainSubstring, `"memoryguard":"labelled"`)
+		})
+	})
+}
+
+func Test_MemoryGuardDone(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.Limit(400 * 1024 * 1024) // we won't actually hit this, right?
+
+		Convey("Done is not closed until we Cancel", func() {
+			var closed bool
+			select {
+			case <-mg.Done():
+				closed = true
+			default:
+			}
+			So(closed, ShouldBeFalse)
+
+			mg.Cancel()
+			<-mg.Done()
+			So(mg.running.Load(), ShouldBeFalse)
+		})
+	})
+}
+
+func Test_MemoryGuardSampleLatency(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with a TickSource", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us)
+		mg.TickSource = tick
+		mg.Limit(400 * 1024 * 1024) // we won't actually hit this, right?
+
+		Convey("SampleLatency is empty until we tick, and then populated", func() {
+			So(mg.SampleLatency().Count, ShouldEqual, 0)
+
+			tick <- time.Now()
+			tick <- time.Now() // the second tick can't be received until the first sample is recorded
+			mg.CancelWait()
+
+			lat := mg.SampleLatency()
+			So(lat.Count, ShouldBeGreaterThanOrEqualTo, 1)
+			So(lat.Last, ShouldBeGreaterThan, 0)
+			So(lat.Max, ShouldBeGreaterThanOrEqualTo, lat.Avg)
+		})
+	})
+}
+
+func Test_MemoryGuardLimitRelative(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with a relative limit", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us)
+		mg.TickSource = tick
+		So(mg.LimitRelative(400*1024*1024), ShouldBeNil) // we won't actually hit this, right?
+		defer mg.Cancel()
+
+		Convey("there is no baseline or effective limit until we tick", func() {
+			So(mg.Baseline(), ShouldEqual, 0)
+			So(mg.EffectiveLimit(), ShouldEqual, 0)
+
+			tick <- time.Now()
+			tick <- time.Now() // the second tick can't be received until the first sample is processed
+
+			So(mg.Baseline(), ShouldBeGreaterThan, 0)
+			So(mg.EffectiveLimit(), ShouldEqual, mg.Baseline()+400*1024*1024)
+			So(mg.running.Load(), ShouldBeTrue)
+		})
+
+		Convey("if we call Limit() or LimitRelative() again it refuses", func() {
+			So(mg.Limit(400*1024*1024), ShouldEqual, LimitOnceError)
+			So(mg.LimitRelative(400*1024*1024), ShouldEqual, LimitOnceError)
+		})
+	})
+
+	Convey("When a MemoryGuard is created with a relative Limit of 0, it refuses", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		So(mg.LimitRelative(0), ShouldEqual, LimitZeroError)
+	})
+}
+
+func Test_MemoryGuardCheckNow(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is OnDemand on us", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.OnDemand = true
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+
+		Convey("CheckNow refuses before Limit", func() {
+			_, _, err := mg.CheckNow()
+			So(err, ShouldEqual, LimitNotSetError)
+		})
+
+		Convey("and a high Limit, no goro runs, and CheckNow is under", func() {
+			So(mg.Limit(400*1024*1024), ShouldBeNil) // we won't actually hit this, right?
+			So(mg.running.Load(), ShouldBeFalse)
+
+			pss, over, err := mg.CheckNow()
+			So(err, ShouldBeNil)
+			So(over, ShouldBeFalse)
+			So(pss, ShouldBeGreaterThan, 0)
+			So(mg.PSS(), ShouldEqual, pss)
+		})
+
+		Convey("and a really low Limit, CheckNow is over, and we'll get killed", func() {
+			So(mg.Limit(1024), ShouldBeNil) // 1KB
+
+			_, over, err := mg.CheckNow()
+			So(err, ShouldBeNil)
+			So(over, ShouldBeTrue)
+			<-mg.KillChan // already closed
+
+			_, over, err = mg.CheckNow() // and again doesn't panic
+			So(err, ShouldBeNil)
+			So(over, ShouldBeTrue)
+		})
+	})
+}
+
+func Test_MemoryGuardSimulate(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is simulating, without a procfs", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us)
+		mg.ProcRoot = t.TempDir()
+		mg.SimulateChan = sim
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("values under the limit are fine, and a value over it gets us killed", func() {
+			sim <- 100
+			sim <- 1000
+			sim <- 1000 // the third value can't be received until the second is processed
+			So(mg.running.Load(), ShouldBeTrue)
+			So(mg.PSS(), ShouldEqual, 1000)
+
+			sim <- 1001
+			<-mg.KillChan // wait for the kill
+			So(mg.PSS(), ShouldEqual, 1001)
+		})
+	})
+}
+
+func Test_MemoryGuardSampleTimeout(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard has a SampleTimeout, and smaps hangs", t, func() {
+		// A FIFO with no writer blocks the open forever, just like a hung network filesystem.
+		root := t.TempDir()
+		os.WriteFile(filepath.Join(root, "stat"), []byte{}, 0644)
+		os.Mkdir(filepath.Join(root, "1"), 0755)
+		smaps := filepath.Join(root, "1", "smaps")
+		So(syscall.Mkfifo(smaps, 0644), ShouldBeNil)
+
+		proc, _ := os.FindProcess(1)
+		mg := New(proc)
+		mg.ProcRoot = root
+		mg.SampleTimeout = 10 * time.Millisecond
+		mg.OnDemand = true
+		mg.Limit(400 * 1024 * 1024)
+
+		Convey("the sample times out with an error", func() {
+			_, _, err := mg.CheckNow()
+			So(err, ShouldEqual, SampleTimeoutError)
+
+			// Unwedge the abandoned sample
+			w, err := os.OpenFile(smaps, os.O_WRONLY, 0)
+			So(err, ShouldBeNil)
+			w.Close()
+		})
+	})
+}
+
+func Test_MemoryGuardMutateAfterLimit(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.Name = "before"
+		mg.Interval = time.Millisecond
+		mg.StatsFrequency = time.Millisecond
+		mg.Limit(400 * 1024 * 1024) // we won't actually hit this, right?
+		defer mg.Cancel()
+
+		Convey("mutating the fields afterward doesn't race, and has no effect", func() {
+			for range 10 {
+				mg.Name = "after"
+				mg.Interval = time.Hour
+				mg.StatsFrequency = time.Hour
+				mg.DebugOut = nil
+				time.Sleep(time.Millisecond)
+			}
+			So(mg.name(), ShouldEqual, "before")
+			So(mg.SampleLatency().Count, ShouldBeGreaterThan, 1)
+		})
+	})
+}
+
 func Test_MemoryGuardMaxPSS(t *testing.T) {
 	defer leaktest.Check(t)()
 
@@ -289,7 +580,7 @@ func Test_GetPSS_Pseudoequality(t *testing.T) {
 	var okDelta = 50 * 1024 // +/- 50k deviation
 
 	Convey("", t, FailureContinues, func() {
-		pss, pssErr := getPss(pid)
+		pss, pssErr := getPss(DefaultProcRoot, pid)
 
 		pss2, pss2Err := getPss2(pid)
 
@@ -312,7 +603,7 @@ func Benchmark_getpss(b *testing.B) {
 		err error
 	)
 	for b.Loop() {
-		pss, err = getPss(pid)
+		pss, err = getPss(DefaultProcRoot, pid)
 		if err != nil {
 			b.Fatalf("Error! %s!\n", err)
 		}
@@ -407,3 +698,429 @@ func getPss2(pid int) (int64, error) {
 
 	return res * 1024, nil
 }
+
+func Test_MemoryGuardHeadroom(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard samples under and then over its limit", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Headroom(), ShouldEqual, 0)
+		So(mg.HeadroomFraction(), ShouldEqual, 0)
+		So(mg.OverLimit(), ShouldBeFalse)
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		mg.SimulateChan <- 250
+		mg.SimulateChan <- 250 // the second value can't be received until the first is processed
+		So(mg.Headroom(), ShouldEqual, 750)
+		So(mg.Overage(), ShouldEqual, 0)
+		So(mg.HeadroomFraction(), ShouldEqual, 0.75)
+		So(mg.OverLimit(), ShouldBeFalse)
+
+		mg.SimulateChan <- 1200
+		<-mg.KillChan // wait for the kill
+		So(mg.Headroom(), ShouldEqual, 0)
+		So(mg.Overage(), ShouldEqual, 200)
+		So(mg.HeadroomFraction(), ShouldEqual, 0)
+		So(mg.OverLimit(), ShouldBeTrue)
+	})
+}
+
+func Test_MemoryGuardConfigureStart(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is configured before its process exists", t, func() {
+		mg := Configure(1024, WithInterval(time.Millisecond), WithKillSignal(syscall.SIGTERM, 0)) // 1KB
+		So(mg.Limit(1024), ShouldEqual, LimitNilProcessError)
+
+		cmd := exec.Command("sleep", "30")
+		So(cmd.Start(), ShouldBeNil)
+		So(mg.Start(cmd.Process), ShouldBeNil)
+		So(mg.Start(cmd.Process), ShouldEqual, LimitOnceError)
+
+		<-mg.KillChan // wait for the kill
+		So(cmd.Wait().Error(), ShouldEqual, "signal: terminated")
+		So(mg.name(), ShouldEqual, strconv.Itoa(cmd.Process.Pid))
+	})
+
+	Convey("When a MemoryGuard is configured without a limit", t, func() {
+		mg := Configure(0)
+		us, _ := os.FindProcess(os.Getpid())
+		So(mg.Start(us), ShouldEqual, LimitZeroError)
+	})
+}
+
+func Test_MemoryGuardZombie(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a guarded process exits, but isn't reaped", t, func() {
+		cmd := exec.Command("true")
+		So(cmd.Start(), ShouldBeNil)
+		defer cmd.Wait()
+		mg := New(cmd.Process, WithInterval(time.Millisecond))
+		So(mg.Limit(1024*1024*1024), ShouldBeNil)
+
+		<-mg.Done()
+		So(isZombie(DefaultProcRoot, cmd.Process.Pid), ShouldBeTrue)
+		So(mg.StopReason(), ShouldEqual, ReasonZombie)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+		<-mg.ExitChan // closed, as it exited on its own
+	})
+}
+
+func Test_MemoryGuardProcessExited(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a guarded process exits, and is reaped", t, func() {
+		var exited atomic.Int64
+
+		cmd := exec.Command("sleep", "10")
+		So(cmd.Start(), ShouldBeNil)
+		tick := make(chan time.Time)
+		mg := New(cmd.Process, WithTickSource(tick), WithOnExit(func(pid int) { exited.Store(int64(pid)) }))
+		So(mg.Limit(1024*1024*1024), ShouldBeNil)
+
+		tick <- time.Now()
+		for mg.SampleLatency().Count == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		So(cmd.Process.Kill(), ShouldBeNil)
+		cmd.Wait()
+		tick <- time.Now()
+
+		<-mg.ExitChan
+		<-mg.Done()
+		So(mg.StopReason(), ShouldEqual, ReasonProcessExited)
+		So(mg.StopReason().String(), ShouldEqual, "ProcessExited")
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+		So(mg.WasKilled(), ShouldBeFalse)
+		So(exited.Load(), ShouldEqual, cmd.Process.Pid)
+	})
+}
+
+func Test_MemoryGuardCancelFromCallback(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard's OnSample calls CancelWait", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.OnSample = func(Sample) { mg.CancelWait() }
+		So(mg.Limit(1000), ShouldBeNil)
+
+		mg.SimulateChan <- 500
+		<-mg.Done() // doesn't deadlock
+		So(mg.StopReason(), ShouldEqual, ReasonCancel)
+	})
+
+	Convey("When a simulating MemoryGuard's OnBreach calls Close", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.OnBreach = func(*BreachContext) bool { mg.Close(); return false }
+		So(mg.Limit(1000), ShouldBeNil)
+
+		mg.SimulateChan <- 2000
+		<-mg.Done() // doesn't deadlock
+		So(mg.StopReason(), ShouldEqual, ReasonCancel)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+		So(mg.WasKilled(), ShouldBeFalse)
+	})
+}
+
+func Test_MemoryGuardCancelWaitDuringCallback(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When CancelWait is called from another goro while a simulating MemoryGuard's OnSample is running", t, func() {
+		var (
+			entered  = make(chan struct{})
+			release  = make(chan struct{})
+			returned = make(chan struct{})
+		)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.OnSample = func(Sample) {
+			close(entered)
+			<-release
+		}
+		So(mg.Limit(1000), ShouldBeNil)
+
+		mg.SimulateChan <- 500
+		<-entered
+		go func() {
+			mg.CancelWait()
+			close(returned)
+		}()
+
+		Convey("it still waits until the Limit goro is done", func() {
+			time.Sleep(20 * time.Millisecond)
+			select {
+			case <-returned:
+				So("CancelWait returned early", ShouldBeEmpty)
+			default:
+			}
+
+			close(release)
+			<-returned
+			So(mg.running.Load(), ShouldBeFalse)
+			So(mg.StopReason(), ShouldEqual, ReasonCancel)
+		})
+	})
+}
+
+func Test_MemoryGuardReset(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard is reset between two full cycles", t, func() {
+		var drains int
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithDrain(func() { drains++ }, 0.5, nil, 0))
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+
+		for range 2 {
+			sim := make(chan int64)
+			mg.SimulateChan = sim
+			So(mg.Limit(1000), ShouldBeNil)
+			So(mg.Reset(), ShouldEqual, ResetRunningError)
+
+			sim <- 600
+			sim <- 2000
+			<-mg.KillChan // wait for the kill
+			<-mg.Done()
+			So(mg.Reason(), ShouldEqual, TriggerLimit)
+			So(mg.StopReason(), ShouldEqual, ReasonKill)
+			So(len(mg.thresholds), ShouldEqual, 1)
+
+			So(mg.Reset(), ShouldBeNil)
+			So(mg.KillEvent(), ShouldBeNil)
+			So(mg.StopReason(), ShouldEqual, ReasonNone)
+			So(mg.PSS(), ShouldEqual, 0)
+			So(len(mg.thresholds), ShouldEqual, 0)
+		}
+		So(drains, ShouldEqual, 2)
+	})
+}
+
+func Test_MemoryGuardPid(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is asked for its process", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		So(mg.Pid(), ShouldEqual, os.Getpid())
+		So(mg.Process(), ShouldEqual, us)
+
+		Convey("and it has none, Pid is -1", func() {
+			mg := New(nil)
+			So(mg.Pid(), ShouldEqual, -1)
+			So(mg.Process(), ShouldBeNil)
+		})
+	})
+}
+
+func Test_MemoryGuardOnRecover(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard's sampling fails for a while and recovers", t, func() {
+		var recoveries []int
+
+		root := fakeProc(t, 1024, 1024)
+		smaps := filepath.Join(root, "1", "smaps")
+		tick := make(chan time.Time)
+		mg := New(&os.Process{Pid: 1}, WithProcRoot(root), WithTickSource(tick), WithOnRecover(func(after int) {
+			recoveries = append(recoveries, after)
+		}))
+		So(mg.Limit(1024*1024), ShouldBeNil)
+
+		// sample ticks, and waits until the sample has been taken, so we can break things between them
+		sample := func() {
+			n := mg.SampleLatency().Count
+			tick <- time.Now()
+			for mg.SampleLatency().Count == n {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		sample()
+		os.Rename(smaps, smaps+".bak")
+		sample()
+		sample()
+		os.Rename(smaps+".bak", smaps)
+		sample()
+		sample()
+		tick <- time.Now() // the last value can't be received until the previous is processed
+		mg.CancelWait()
+
+		So(recoveries, ShouldResemble, []int{2})
+	})
+}
+
+func Test_MemoryGuardKillNotify(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard kills, is Reset, and kills again", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+
+		mg.SimulateChan = make(chan int64)
+		So(mg.Limit(1000), ShouldBeNil)
+		first := mg.KillNotify()
+		mg.SimulateChan <- 2000
+		<-first // wait for the kill
+		<-mg.Done()
+
+		So(mg.Reset(), ShouldBeNil)
+		second := mg.KillNotify()
+		So(second, ShouldNotEqual, first)
+		select {
+		case <-second:
+			t.Fatal("the new KillNotify channel is already closed")
+		default:
+		}
+
+		mg.SimulateChan = make(chan int64)
+		So(mg.Limit(1000), ShouldBeNil)
+		So(mg.KillNotify(), ShouldEqual, second)
+		mg.SimulateChan <- 2000
+		<-second // wait for the kill
+		<-mg.Done()
+	})
+}
+
+func Test_MemoryGuardIntervalJitter(t *testing.T) {
+	Convey("When a MemoryGuard has IntervalJitter", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		c := New(us, WithInterval(time.Second), WithIntervalJitter(time.Second)).snapshot()
+
+		Convey("each interval is within [Interval, Interval+IntervalJitter), and they vary", func() {
+			seen := make(map[time.Duration]bool)
+			for range 100 {
+				i := c.nextInterval()
+				So(i, ShouldBeGreaterThanOrEqualTo, time.Second)
+				So(i, ShouldBeLessThan, 2*time.Second)
+				seen[i] = true
+			}
+			So(len(seen), ShouldBeGreaterThan, 1)
+		})
+
+		Convey("without it, each interval is exact", func() {
+			c := New(us, WithInterval(time.Second)).snapshot()
+			So(c.nextInterval(), ShouldEqual, time.Second)
+		})
+	})
+}
+
+func Test_MemoryGuardCloseKillChanOnStop(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard with CloseKillChanOnStop is cancelled", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithCloseKillChanOnStop())
+		mg.SimulateChan = make(chan int64)
+		So(mg.Limit(1000), ShouldBeNil)
+		mg.SimulateChan <- 100
+		mg.Cancel()
+
+		<-mg.KillChan // doesn't block forever
+		So(mg.StopReason(), ShouldEqual, ReasonCancel)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+	})
+
+	Convey("When a simulating MemoryGuard with CloseKillChanOnStop kills", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithCloseKillChanOnStop())
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		mg.SimulateChan <- 2000
+
+		<-mg.KillChan
+		<-mg.Done() // and doesn't close it twice
+		So(mg.StopReason(), ShouldEqual, ReasonKill)
+	})
+}
+
+func Test_MemoryGuardStaleAfter(t *testing.T) {
+	Convey("When an on-demand MemoryGuard with StaleAfter has sampled", t, func() {
+		clock := &stepClock{}
+		clock.now.Store(time.Now().UnixNano())
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithClock(clock), WithStaleAfter(time.Minute), WithOnDemand())
+		So(mg.LastSampleTime().IsZero(), ShouldBeTrue)
+		So(mg.Limit(1024*1024*1024*1024), ShouldBeNil)
+		_, _, err := mg.CheckNow()
+		So(err, ShouldBeNil)
+		So(mg.LastSampleTime(), ShouldEqual, clock.Now())
+
+		mg.lastPss.Store(1) // so we can tell the cached value from a fresh one
+		So(mg.Stale(), ShouldBeFalse)
+		So(mg.PSS(), ShouldEqual, 1)
+
+		Convey("once it is older than StaleAfter, PSS samples anew", func() {
+			clock.step(2 * time.Minute)
+			So(mg.Stale(), ShouldBeTrue)
+			pss := mg.PSS()
+			So(pss, ShouldBeGreaterThan, 1)
+
+			// and that is the last known value until it, too, is older than StaleAfter
+			So(mg.Stale(), ShouldBeFalse)
+			So(mg.LastSampleTime(), ShouldEqual, clock.Now())
+			So(mg.PSS(), ShouldEqual, pss)
+		})
+	})
+}
+
+func Test_MemoryGuardForceSample(t *testing.T) {
+	Convey("When a MemoryGuard is forced to sample", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.lastPss.Store(1) // so we can tell the cached value from a fresh one
+
+		pss, err := mg.ForceSample()
+		So(err, ShouldBeNil)
+		So(pss, ShouldBeGreaterThan, 1)
+		So(mg.PSS(), ShouldEqual, pss)
+		So(mg.PeakPSS(), ShouldEqual, pss)
+		So(mg.LastSampleTime().IsZero(), ShouldBeFalse)
+		So(mg.SampleCount(), ShouldEqual, 1)
+	})
+
+	Convey("When a MemoryGuard without a process is forced to sample, it errors", t, func() {
+		_, err := New(nil).ForceSample()
+		So(err, ShouldEqual, LimitNilProcessError)
+	})
+}
+
+func Test_MemoryGuardWaitUntilBelow(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard waits for its process to drop below a value", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithInterval(time.Millisecond))
+		mg.SimulateChan = make(chan int64)
+		mg.lastPss.Store(2000)
+
+		Convey("it times out if it doesn't", func() {
+			So(mg.WaitUntilBelow(1000, 10*time.Millisecond), ShouldEqual, WaitTimeoutError)
+		})
+
+		Convey("it returns once it does", func() {
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				mg.lastPss.Store(500)
+			}()
+			So(mg.WaitUntilBelow(1000, time.Minute), ShouldBeNil)
+			So(mg.PSS(), ShouldEqual, 500)
+		})
+	})
+
+	Convey("When a MemoryGuard without a process waits, it errors", t, func() {
+		So(New(nil).WaitUntilBelow(1000, time.Second), ShouldEqual, LimitNilProcessError)
+	})
+}
@@ -0,0 +1,34 @@
+package memoryguard
+
+import (
+	"time"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// boost is a temporary addition to the limit, from BoostLimit.
+type boost struct {
+	extra int64
+	until time.Time
+}
+
+// BoostLimit adds extra Bytes to the limit for d, after which it reverts on its own. This is intended
+// for known, transient spikes (e.g. a callback that is about to do something expensive). A subsequent
+// BoostLimit replaces any active boost, and a zero or negative extra or d cancels it.
+// The boost is reflected in EffectiveLimit, and everything derived from it.
+func (m *MemoryGuard) BoostLimit(extra int64, d time.Duration) {
+	if extra <= 0 || d <= 0 {
+		m.boost.Store(nil)
+		return
+	}
+	m.boost.Store(&boost{extra: extra, until: m.now().Add(d)})
+	m.logf(LevelDebug, []any{"name", m.name(), "boost", extra, "duration", d}, "[%s] MemoryGuard Limit boosted by %s for %s\n", m.name(), humanity.ByteFormat(extra), d)
+}
+
+// activeBoost returns the extra Bytes of an unexpired BoostLimit, or 0.
+func (m *MemoryGuard) activeBoost() int64 {
+	if b := m.boost.Load(); b != nil && m.now().Before(b.until) {
+		return b.extra
+	}
+	return 0
+}
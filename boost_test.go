@@ -0,0 +1,82 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardBoostLimit(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard has its limit boosted", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		mg.BoostLimit(500, time.Hour)
+		So(mg.EffectiveLimit(), ShouldEqual, 1500)
+
+		Convey("samples under the boosted limit don't kill", func() {
+			mg.SimulateChan <- 1400
+			mg.SimulateChan <- 1400 // the second value can't be received until the first is processed
+			mg.SimulateChan <- 1400
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+			So(mg.Headroom(), ShouldEqual, 100)
+
+			Convey("but once the boost expires, they do", func() {
+				mg.BoostLimit(500, time.Millisecond)
+				time.Sleep(5 * time.Millisecond)
+				So(mg.EffectiveLimit(), ShouldEqual, 1000)
+
+				mg.SimulateChan <- 1400
+				<-mg.KillChan // wait for the kill
+				So(mg.Reason(), ShouldEqual, TriggerLimit)
+			})
+		})
+
+		Convey("a zero boost cancels it", func() {
+			mg.BoostLimit(0, time.Hour)
+			So(mg.EffectiveLimit(), ShouldEqual, 1000)
+		})
+	})
+}
+
+func Test_MemoryGuardBoostLimitRelative(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard with a relative limit is boosted before it has a baseline", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.LimitRelative(1000), ShouldBeNil)
+		defer mg.CancelWait()
+		mg.BoostLimit(500, time.Hour)
+
+		Convey("an empty sample doesn't overflow the limit, and kill", func() {
+			mg.SimulateChan <- 0
+			select {
+			case mg.SimulateChan <- 0: // the second value can't be received until the first is processed
+			case <-mg.KillChan:
+			}
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+			So(mg.KillEvent(), ShouldBeNil)
+
+			Convey("and once there is a baseline, the boost applies to it", func() {
+				mg.SimulateChan <- 2000
+				mg.SimulateChan <- 3400
+				mg.SimulateChan <- 3400
+				So(mg.Baseline(), ShouldEqual, 2000)
+				So(mg.EffectiveLimit(), ShouldEqual, 3500)
+				So(mg.Reason(), ShouldEqual, TriggerNone)
+			})
+		})
+	})
+}
@@ -0,0 +1,85 @@
+package memoryguard
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// historySize is the number of recent samples kept for BreachContext.History
+const historySize = 32
+
+// BreachContext is everything known about a breach, given to OnBreach, along with
+// methods to act on the process using the MemoryGuard's machinery.
+type BreachContext struct {
+	// Name is the name of the MemoryGuard
+	Name string
+	// Trigger is the condition that fired
+	Trigger TriggerType
+	// Time is when the condition fired
+	Time time.Time
+	// Sample is the value that fired the condition
+	Sample int64
+	// Peak is the highest sample seen by the MemoryGuard
+	Peak int64
+	// Limit is the limit the Sample was compared against
+	Limit int64
+	// History is up to the last 32 samples, oldest first
+	History []Sample
+	// Metrics are the Metrics that were over the limit, if CompositeMetrics were set
+	Metrics []Metric
+	// Gauges are the values of the gauges from AddGauge at the time, by name, if any
+	Gauges map[string]float64
+	// Labels are the Labels of the MemoryGuard, if any
+	Labels map[string]string
+
+	m *MemoryGuard
+}
+
+// Kill kills the process as the MemoryGuard would have without OnBreach: ShutdownFunc, OnShutdown, KillSignal,
+// etc. The error is also set as KillError.
+func (b *BreachContext) Kill() error {
+	b.m.KillError = b.m.act()
+	return b.m.KillError
+}
+
+// Signal sends sig to the process, e.g. syscall.SIGSTOP to throttle it.
+func (b *BreachContext) Signal(sig os.Signal) error {
+	if b.m.conf().nokill {
+		return nil
+	}
+	return b.m.signal(sig)
+}
+
+// Growing returns true if the latest sample in History is larger than the oldest.
+func (b *BreachContext) Growing() bool {
+	return len(b.History) > 1 && b.History[len(b.History)-1].Value > b.History[0].Value
+}
+
+// history is a ring of the last historySize samples.
+type history struct {
+	lock sync.Mutex
+	ring [historySize]Sample
+	n    int
+}
+
+// add adds s, overwriting the oldest sample if full.
+func (h *history) add(s Sample) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.ring[h.n%historySize] = s
+	h.n++
+}
+
+// samples returns the samples, oldest first.
+func (h *history) samples() []Sample {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.n <= historySize {
+		return append([]Sample(nil), h.ring[:h.n]...)
+	}
+	start := h.n % historySize
+	return append(append([]Sample(nil), h.ring[start:]...), h.ring[:start]...)
+}
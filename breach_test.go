@@ -0,0 +1,105 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_History(t *testing.T) {
+	Convey("When more samples than fit are added to a history", t, func() {
+		var h history
+		So(h.samples(), ShouldBeEmpty)
+		for i := range historySize + 5 {
+			h.add(Sample{Value: int64(i)})
+		}
+
+		Convey("only the latest are kept, oldest first", func() {
+			s := h.samples()
+			So(len(s), ShouldEqual, historySize)
+			So(s[0].Value, ShouldEqual, 5)
+			So(s[historySize-1].Value, ShouldEqual, historySize+4)
+		})
+	})
+}
+
+func Test_MemoryGuardOnBreach(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard's OnBreach only kills if usage is growing", t, func() {
+		var (
+			calls   int
+			last    *BreachContext
+			signals int
+		)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithOnBreach(func(bc *BreachContext) bool {
+			calls++
+			last = bc
+			if !bc.Growing() {
+				return false
+			}
+			return bc.Kill() == nil
+		}))
+		mg.SimulateChan = make(chan int64)
+		mg.signaller = func(os.Signal) error { signals++; return nil }
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		mg.SimulateChan <- 2000
+		mg.SimulateChan <- 1500
+		mg.SimulateChan <- 500 // the third value can't be received until the second is processed
+		So(calls, ShouldEqual, 2)
+		So(signals, ShouldEqual, 0)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+
+		mg.SimulateChan <- 2500
+		<-mg.KillChan // wait for the kill
+		So(calls, ShouldEqual, 3)
+		So(signals, ShouldEqual, 1)
+		So(last.Trigger, ShouldEqual, TriggerLimit)
+		So(last.Sample, ShouldEqual, 2500)
+		So(last.Peak, ShouldEqual, 2500)
+		So(len(last.History), ShouldEqual, 4)
+		So(mg.Reason(), ShouldEqual, TriggerLimit)
+	})
+}
+
+func Test_MemoryGuardShouldKill(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard only kills after three consecutive samples over its limit", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithShouldKill(func(sample, limit int64, history []Sample) bool {
+			if len(history) < 3 {
+				return false
+			}
+			for _, s := range history[len(history)-3:] {
+				if s.Value <= limit {
+					return false
+				}
+			}
+			return true
+		}))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("blips over it are ignored, but a sustained breach isn't", func() {
+			for _, v := range []int64{2000, 2000, 500, 2000, 2000, 500} {
+				mg.SimulateChan <- v
+			}
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+
+			mg.SimulateChan <- 2000
+			mg.SimulateChan <- 2000
+			mg.SimulateChan <- 2000
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerLimit)
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package memoryguard
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroupRoot is where cgroup v2 is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// NewCgroupGuard takes the path to a cgroup v2 directory (e.g. "/sys/fs/cgroup/system.slice/foo.service"),
+// and optional Options, and returns a MemoryGuard for the cgroup rather than a process: each sample is the
+// cgroup's memory.current, and on breach, every process in cgroup.procs is signalled with KillSignal (or,
+// for os.Kill, the whole cgroup is killed via cgroup.kill where the kernel has it). KillConfirmTimeout waits
+// for the cgroup to be empty. Everything else works as usual, except what only makes sense for a single
+// process (there is no Process, Pid is -1, and Metric, KillGroup, UsePidfd, QuiesceGC, ReportTopMappings, and Breakdown are
+// ignored or fail). Name defaults to the cgroup path. Returns CgroupUnavailableError if the path isn't a
+// cgroup v2 directory with the memory controller enabled. cgroup v1 is not supported.
+func NewCgroupGuard(cgroupPath string, opts ...Option) (*MemoryGuard, error) {
+	if _, err := os.Stat(filepath.Join(cgroupPath, "memory.current")); err != nil {
+		return nil, CgroupUnavailableError
+	}
+
+	m := New(nil, opts...)
+	m.cgroup = cgroupPath
+	if m.Name == "" {
+		m.Name = cgroupPath
+	}
+	return m, nil
+}
+
+// Cgroup returns the cgroup path given to NewCgroupGuard, or "" if this MemoryGuard is for a process.
+func (m *MemoryGuard) Cgroup() string {
+	return m.cgroup
+}
+
+// getCgroupInfo takes a cgroup path, and returns a SampleInfo for its memory.current, or an error.
+func getCgroupInfo(path string) (SampleInfo, error) {
+	file := filepath.Join(path, "memory.current")
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	current, err := strconv.ParseInt(string(bytes.TrimSpace(b)), 10, 64)
+	if err != nil {
+		return SampleInfo{}, CgroupFormatError
+	}
+	return SampleInfo{Bytes: current, Source: file}, nil
+}
+
+// cgroupMemoryMax takes a cgroup path, and returns the lowest memory.max of it and its ancestors (up to
+// cgroupRoot), or 0 if none of them has one.
+func cgroupMemoryMax(path string) int64 {
+	var lowest int64
+	for dir := filepath.Clean(path); strings.HasPrefix(dir, cgroupRoot+"/"); dir = filepath.Dir(dir) {
+		b, err := os.ReadFile(filepath.Join(dir, "memory.max"))
+		if err != nil {
+			continue
+		}
+		l, err := strconv.ParseInt(string(bytes.TrimSpace(b)), 10, 64)
+		if err != nil {
+			// "max"
+			continue
+		}
+		if lowest == 0 || l < lowest {
+			lowest = l
+		}
+	}
+	return lowest
+}
+
+// procCgroup takes a procfs root and a pid, and returns the path of its cgroup v2, or "" if it isn't in one.
+func procCgroup(root string, pid int) string {
+	b, err := os.ReadFile(procPath(root, pid, "cgroup"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if rel, ok := bytes.CutPrefix(line, []byte("0::")); ok {
+			return filepath.Join(cgroupRoot, string(rel))
+		}
+	}
+	return ""
+}
+
+// cgroupProcs returns the pids in the cgroup.
+func cgroupProcs(path string) []int {
+	b, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+	if err != nil {
+		return nil
+	}
+
+	var pids []int
+	for _, f := range bytes.Fields(b) {
+		if pid, err := strconv.Atoi(string(f)); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// waitCgroupEmpty polls cgroup.procs until the cgroup has no processes, returning true, or the timeout
+// passes on clock, returning false.
+func waitCgroupEmpty(clock Clock, path string, timeout time.Duration) bool {
+	deadline := clock.Now().Add(timeout)
+	for {
+		if len(cgroupProcs(path)) == 0 {
+			return true
+		} else if clock.Now().After(deadline) {
+			return false
+		}
+		sleep(clock, 10*time.Millisecond)
+	}
+}
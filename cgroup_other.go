@@ -0,0 +1,10 @@
+//go:build !unix
+
+package memoryguard
+
+import "os"
+
+// signalCgroup returns CgroupUnavailableError, as cgroups are only on Linux.
+func signalCgroup(path string, sig os.Signal) error {
+	return CgroupUnavailableError
+}
@@ -0,0 +1,91 @@
+package memoryguard
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeCgroup returns a directory that looks like a cgroup v2 with the memory.current and pids given.
+func fakeCgroup(t *testing.T, current int64, pids ...int) string {
+	dir := t.TempDir()
+	procs := ""
+	for _, pid := range pids {
+		procs += strconv.Itoa(pid) + "\n"
+	}
+	os.WriteFile(filepath.Join(dir, "memory.current"), []byte(strconv.FormatInt(current, 10)+"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(procs), 0644)
+	return dir
+}
+
+func Test_CgroupGuard(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When NewCgroupGuard is given a directory that isn't a cgroup, it refuses", t, func() {
+		_, err := NewCgroupGuard(t.TempDir())
+		So(err, ShouldEqual, CgroupUnavailableError)
+	})
+
+	Convey("When a cgroup's memory.current is read", t, func() {
+		info, err := getCgroupInfo(fakeCgroup(t, 4096))
+		So(err, ShouldBeNil)
+		So(info.Bytes, ShouldEqual, 4096)
+
+		dir := fakeCgroup(t, 0)
+		os.WriteFile(filepath.Join(dir, "memory.current"), []byte("max\n"), 0644)
+		_, err = getCgroupInfo(dir)
+		So(err, ShouldEqual, CgroupFormatError)
+	})
+
+	Convey("When a CgroupGuard's cgroup is over its limit", t, func() {
+		cmd := exec.Command("sleep", "10")
+		So(cmd.Start(), ShouldBeNil)
+		dir := fakeCgroup(t, 2*1024*1024, cmd.Process.Pid)
+
+		mg, err := NewCgroupGuard(dir)
+		So(err, ShouldBeNil)
+		So(mg.Cgroup(), ShouldEqual, dir)
+		So(mg.name(), ShouldEqual, dir)
+		mg.OnDemand = true
+		So(mg.Limit(1024*1024), ShouldBeNil)
+
+		pss, over, err := mg.CheckNow()
+		So(err, ShouldBeNil)
+		So(pss, ShouldEqual, 2*1024*1024)
+		So(over, ShouldBeTrue)
+		<-mg.KillChan
+
+		Convey("every process in it is killed", func() {
+			err := cmd.Wait()
+			So(err, ShouldNotBeNil)
+			So(err.(*exec.ExitError).Sys().(syscall.WaitStatus).Signal(), ShouldEqual, syscall.SIGKILL)
+		})
+	})
+
+	Convey("When a CgroupGuard is CancelKilled, there is no process to signal, only the cgroup", t, func() {
+		cmd := exec.Command("sleep", "10")
+		So(cmd.Start(), ShouldBeNil)
+		dir := fakeCgroup(t, 1024, cmd.Process.Pid)
+
+		mg, err := NewCgroupGuard(dir, WithKillSignal(syscall.SIGTERM, 0))
+		So(err, ShouldBeNil)
+		So(mg.Process(), ShouldBeNil)
+		So(mg.Pid(), ShouldEqual, -1)
+		So(mg.Limit(1024*1024), ShouldBeNil)
+		pss, err := mg.ForceSample()
+		So(err, ShouldBeNil)
+		So(pss, ShouldEqual, 1024)
+
+		So(mg.CancelKill(), ShouldBeNil) // would panic if the nil Process were signalled
+		So(mg.KillEvent().Trigger, ShouldEqual, TriggerCancel)
+		err = cmd.Wait()
+		So(err, ShouldNotBeNil)
+		So(err.(*exec.ExitError).Sys().(syscall.WaitStatus).Signal(), ShouldEqual, syscall.SIGTERM)
+	})
+}
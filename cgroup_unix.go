@@ -0,0 +1,36 @@
+//go:build unix
+
+package memoryguard
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// signalCgroup sends sig to every process in the cgroup, using cgroup.kill for os.Kill if it exists.
+func signalCgroup(path string, sig os.Signal) error {
+	ssig, ok := sig.(syscall.Signal)
+	if !ok {
+		return KillGroupSignalError
+	}
+	if ssig == syscall.SIGKILL {
+		if f, err := os.OpenFile(filepath.Join(path, "cgroup.kill"), os.O_WRONLY, 0); err == nil {
+			_, err = f.WriteString("1")
+			f.Close()
+			if err == nil {
+				return nil
+			}
+		}
+		// Older kernel, do it ourselves
+	}
+
+	var first error
+	for _, pid := range cgroupProcs(path) {
+		if err := syscall.Kill(pid, ssig); err != nil && err != syscall.ESRCH && first == nil {
+			// It may have died in the meantime, which is fine, but anything else isn't
+			first = err
+		}
+	}
+	return first
+}
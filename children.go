@@ -0,0 +1,107 @@
+package memoryguard
+
+import (
+	"bytes"
+	"cmp"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// ProcessUsage is the usage of one process among many, e.g. the descendants of a process with IncludeChildren.
+type ProcessUsage struct {
+	// Pid is the PID of the process
+	Pid int
+	// Cmdline is the command line of the process, with arguments separated by spaces, if it has been read
+	Cmdline string
+	// PSS is the value of the Metric for the process, in Bytes
+	PSS int64
+}
+
+// Children returns the usage of each descendant of the process summed by the last sample, largest first, or nil
+// if IncludeChildren isn't set, or nothing has been sampled. Cmdline is not read.
+func (m *MemoryGuard) Children() []ProcessUsage {
+	if c := m.children.Load(); c != nil {
+		return slices.Clone(*c)
+	}
+	return nil
+}
+
+// topChild returns the largest of the Children of the last sample, or nil if there are none.
+func (m *MemoryGuard) topChild() *ProcessUsage {
+	if c := m.children.Load(); c != nil && len(*c) > 0 {
+		top := (*c)[0]
+		return &top
+	}
+	return nil
+}
+
+// sampleTree returns a SampleInfo for the sum of mt for the process and all of its descendants, or an error if
+// the process itself can't be sampled. Descendants that can't be (e.g. they exited meanwhile) are skipped. If mt
+// is the configured Metric, the usage of each descendant is retained for Children.
+func (m *MemoryGuard) sampleTree(c *config, mt Metric) (SampleInfo, error) {
+	info, err := c.pidMetricInfo(mt, m.proc.Pid)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+
+	var children []ProcessUsage
+	for _, pid := range descendants(c.procRoot, m.proc.Pid) {
+		child, err := c.pidMetricInfo(mt, pid)
+		if err != nil {
+			continue
+		}
+		if info.Bytes, err = addBytes(info.Bytes, child.Bytes); err != nil {
+			return SampleInfo{}, err
+		}
+		info.MappingCount += child.MappingCount
+		children = append(children, ProcessUsage{Pid: pid, PSS: child.Bytes})
+	}
+	if mt == c.metric {
+		slices.SortStableFunc(children, func(a, b ProcessUsage) int {
+			return cmp.Compare(b.PSS, a.PSS)
+		})
+		m.children.Store(&children)
+	}
+	return info, nil
+}
+
+// descendants takes a procfs root and a pid, and returns the pids of all of its live (non-zombie) descendants,
+// found by scanning the parent of every process in procfs.
+func descendants(root string, pid int) []int {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	kids := make(map[int][]int)
+	for _, e := range entries {
+		p, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(procPath(root, p, "stat"))
+		if err != nil {
+			continue
+		}
+		// state ppid follow the parenthesized comm, which may itself contain spaces or parens.
+		i := bytes.LastIndexByte(stat, ')')
+		if i < 0 {
+			continue
+		}
+		fields := bytes.Fields(stat[i+1:])
+		if len(fields) < 2 || fields[0][0] == 'Z' || fields[0][0] == 'X' {
+			continue
+		}
+		if ppid, err := strconv.Atoi(string(fields[1])); err == nil {
+			kids[ppid] = append(kids[ppid], p)
+		}
+	}
+
+	var pids []int
+	for queue := kids[pid]; len(queue) > 0; queue = queue[1:] {
+		pids = append(pids, queue[0])
+		queue = append(queue, kids[queue[0]]...)
+	}
+	return pids
+}
@@ -0,0 +1,69 @@
+package memoryguard
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// startTree starts a shell with two sleeping children in its own process group, and waits for them.
+func startTree() (*exec.Cmd, error) {
+	cmd := exec.Command("bash", "-c", `sleep 30 & sleep 30 & wait`)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	for len(descendants(DefaultProcRoot, cmd.Process.Pid)) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	return cmd, nil
+}
+
+func Test_MemoryGuardIncludeChildren(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard with IncludeChildren samples a process tree", t, func() {
+		cmd, err := startTree()
+		So(err, ShouldBeNil)
+		defer func() {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			cmd.Wait()
+		}()
+
+		mg := New(cmd.Process, WithIncludeChildren())
+		So(mg.Children(), ShouldBeNil)
+		total, err := mg.ForceSample()
+		So(err, ShouldBeNil)
+
+		Convey("the sample is the sum of the process and its children, which are kept, largest first", func() {
+			children := mg.Children()
+			So(children, ShouldHaveLength, 2)
+			So(children[0].PSS, ShouldBeGreaterThanOrEqualTo, children[1].PSS)
+			own, _ := getPss(DefaultProcRoot, cmd.Process.Pid)
+			So(total, ShouldBeGreaterThan, own)
+			So(descendants(DefaultProcRoot, cmd.Process.Pid), ShouldContain, children[0].Pid)
+		})
+	})
+
+	Convey("When a MemoryGuard with IncludeChildren kills a process tree", t, func() {
+		cmd, err := startTree()
+		So(err, ShouldBeNil)
+
+		mg := New(cmd.Process, WithIncludeChildren(), WithKillGroup(), WithInterval(time.Millisecond))
+		So(mg.Limit(1024), ShouldBeNil) // 1KB
+		defer mg.Cancel()
+
+		Convey("the KillEvent has the largest child", func() {
+			<-mg.KillChan // wait for the kill
+			cmd.Wait()
+			top := mg.KillEvent().TopChild
+			So(top, ShouldNotBeNil)
+			So(top.Cmdline, ShouldEqual, "sleep 30")
+			So(top.PSS, ShouldBeGreaterThan, 0)
+		})
+	})
+}
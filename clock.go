@@ -0,0 +1,48 @@
+package memoryguard
+
+import "time"
+
+// Clock is a source of time. The default is the real clock, but a fake one (e.g. memoryguardtest.Clock)
+// may be used to drive Interval, StatsFrequency, PreKillDelay, KillConfirmTimeout, ShutdownGrace,
+// BoostLimit, and the rest of the time-dependent behaviors deterministically, without sleeping.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives the current time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+// Now returns time.Now().
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns time.After(d).
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// now returns the current time, per the Clock.
+func (m *MemoryGuard) now() time.Time {
+	return m.clock().Now()
+}
+
+// clock returns the Clock, or the real one if it is unset.
+func (m *MemoryGuard) clock() Clock {
+	if c := m.cfg.Load(); c != nil {
+		return c.clock
+	} else if m.Clock != nil {
+		return m.Clock
+	}
+	return realClock{}
+}
+
+// sleep waits for d to elapse on clock.
+func sleep(clock Clock, d time.Duration) {
+	if d > 0 {
+		<-clock.After(d)
+	}
+}
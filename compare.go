@@ -0,0 +1,64 @@
+package memoryguard
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// Comparison is the difference in usage between two MemoryGuards' processes at a point in time.
+type Comparison struct {
+	// Time is when the comparison was made
+	Time time.Time
+	// A is the last sample of the first MemoryGuard, in Bytes
+	A int64
+	// B is the last sample of the second MemoryGuard, in Bytes
+	B int64
+	// Diff is B - A, in Bytes, so a positive Diff means B is using more
+	Diff int64
+}
+
+// String returns a compact, human-readable version of the Comparison.
+func (c Comparison) String() string {
+	sign := "+"
+	diff := c.Diff
+	if diff < 0 {
+		sign = "-"
+		diff = -diff
+	}
+	return fmt.Sprintf("A: %s B: %s Diff: %s%s", humanity.ByteFormat(c.A), humanity.ByteFormat(c.B), sign, humanity.ByteFormat(diff))
+}
+
+// CompareGuards returns a channel that receives a Comparison of the last samples of a and b every Interval
+// of a, until either of their Limit goros stop, when it is closed. Both must be guarding (Limit() called),
+// or the comparison will never stop. It only reads the last samples, so it adds no load to either guard.
+// If the receiver falls behind, Comparisons are dropped rather than queued.
+func CompareGuards(a, b *MemoryGuard) <-chan Comparison {
+	out := make(chan Comparison, 1)
+
+	go func() {
+		defer close(out)
+
+		clock, interval := a.clock(), a.conf().interval
+		t := clock.After(interval)
+		for {
+			select {
+			case <-a.Done():
+				return
+			case <-b.Done():
+				return
+			case now := <-t:
+				t = clock.After(interval)
+				xa, xb := a.lastPss.Load(), b.lastPss.Load()
+				select {
+				case out <- Comparison{Time: now, A: xa, B: xb, Diff: xb - xa}:
+				default:
+					// dropped
+				}
+			}
+		}
+	}()
+
+	return out
+}
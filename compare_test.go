@@ -0,0 +1,40 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_CompareGuards(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When two simulating MemoryGuards are compared", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		a, b := New(us, WithInterval(time.Millisecond)), New(us, WithInterval(time.Millisecond))
+		a.SimulateChan, b.SimulateChan = make(chan int64), make(chan int64)
+		So(a.Limit(10000), ShouldBeNil)
+		So(b.Limit(10000), ShouldBeNil)
+
+		a.SimulateChan <- 3000
+		a.SimulateChan <- 3000 // the second value can't be received until the first is processed
+		b.SimulateChan <- 2000
+		b.SimulateChan <- 2000
+
+		comps := CompareGuards(a, b)
+		c := <-comps
+		So(c.A, ShouldEqual, 3000)
+		So(c.B, ShouldEqual, 2000)
+		So(c.Diff, ShouldEqual, -1000)
+		So(c.String(), ShouldContainSubstring, "Diff: -")
+
+		b.CancelWait()
+		for range comps {
+			// drain until closed
+		}
+		a.CancelWait()
+	})
+}
@@ -0,0 +1,58 @@
+package memoryguard
+
+import (
+	"slices"
+	"strconv"
+)
+
+// CompositeMode is how CompositeMetrics are combined with Metric to decide if the limit is breached.
+type CompositeMode int
+
+const (
+	// CompositeAll requires Metric and every one of CompositeMetrics to be over the limit.
+	CompositeAll CompositeMode = iota
+	// CompositeAny requires Metric or any one of CompositeMetrics to be over the limit.
+	CompositeAny
+)
+
+// String returns the name of the CompositeMode
+func (cm CompositeMode) String() string {
+	switch cm {
+	case CompositeAll:
+		return "All"
+	case CompositeAny:
+		return "Any"
+	}
+	return "CompositeMode(" + strconv.Itoa(int(cm)) + ")"
+}
+
+// compositeOver compares xss (the sample of Metric), and a fresh sample of each of CompositeMetrics, to max,
+// returning whether they're over per CompositeMode, and which of them were. With CompositeAll, we stop sampling
+// at the first that isn't over. A metric that can't be sampled isn't over.
+func (m *MemoryGuard) compositeOver(name string, xss, max int64) (bool, []Metric) {
+	c := m.conf()
+
+	var over []Metric
+	if xss > max {
+		over = append(over, c.metric)
+	} else if c.compositeMode == CompositeAll {
+		return false, nil
+	}
+
+	for _, mt := range c.compositeMetrics {
+		v, err := m.sampleMetric(c, mt)
+		if err != nil {
+			m.logf(LevelError, []any{"name", name, "metric", mt, "error", err}, "[%s] MemoryGuard %s Error: %s\n", name, mt, err)
+		}
+		if err == nil && v > max {
+			over = append(over, mt)
+		} else if c.compositeMode == CompositeAll {
+			return false, nil
+		}
+	}
+
+	if len(over) == 0 {
+		return false, nil
+	}
+	return true, slices.Clip(over)
+}
@@ -0,0 +1,66 @@
+package memoryguard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeProc writes a procfs under a temp dir for pid 1, with the given Pss and RSS, in Bytes
+func fakeProc(t *testing.T, pss, rss int64) string {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "stat"), []byte("cpu 0\n"), 0644)
+	os.Mkdir(filepath.Join(root, "1"), 0755)
+	os.WriteFile(filepath.Join(root, "1", "smaps"), fmt.Appendf(nil, "Pss: %d kB\n", pss/1024), 0644)
+	os.WriteFile(filepath.Join(root, "1", "statm"), fmt.Appendf(nil, "1000 %d 0 0 0 0 0\n", rss/int64(os.Getpagesize())), 0644)
+	return root
+}
+
+func Test_MemoryGuardComposite(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	proc := &os.Process{Pid: 1}
+	page := int64(os.Getpagesize())
+
+	Convey("When a MemoryGuard requires all of PSS and RSS to be over, and only RSS is", t, func() {
+		mg := New(proc, WithProcRoot(fakeProc(t, 1024, 100*page)), WithOnDemand(), WithCompositeMetrics(CompositeAll, MetricRSSFast))
+		mg.nokill = true // set internal tunable to not actually kill anything.
+		So(mg.Limit(10*page), ShouldBeNil)
+
+		_, over, err := mg.CheckNow()
+		So(err, ShouldBeNil)
+		So(over, ShouldBeFalse)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+	})
+
+	Convey("When a MemoryGuard requires any of PSS and RSS to be over, and only RSS is", t, func() {
+		mg := New(proc, WithProcRoot(fakeProc(t, 1024, 100*page)), WithOnDemand(), WithCompositeMetrics(CompositeAny, MetricRSSFast))
+		mg.nokill = true // set internal tunable to not actually kill anything.
+		So(mg.Limit(10*page), ShouldBeNil)
+
+		_, over, err := mg.CheckNow()
+		So(err, ShouldBeNil)
+		So(over, ShouldBeTrue)
+		<-mg.KillChan
+		So(mg.Reason(), ShouldEqual, TriggerLimit)
+		So(mg.KillEvent().Metrics, ShouldResemble, []Metric{MetricRSSFast})
+	})
+
+	Convey("When a MemoryGuard requires all of PSS and RSS to be over, and both are", t, func() {
+		mg := New(proc, WithProcRoot(fakeProc(t, 100*page, 100*page)), WithOnDemand(), WithCompositeMetrics(CompositeAll, MetricRSSFast))
+		mg.nokill = true // set internal tunable to not actually kill anything.
+		So(mg.Limit(10*page), ShouldBeNil)
+
+		_, over, err := mg.CheckNow()
+		So(err, ShouldBeNil)
+		So(over, ShouldBeTrue)
+		<-mg.KillChan
+		So(mg.KillEvent().Metrics, ShouldResemble, []Metric{MetricPSS, MetricRSSFast})
+		So(CompositeAll.String(), ShouldEqual, "All")
+		So(CompositeMode(9).String(), ShouldEqual, "CompositeMode(9)")
+	})
+}
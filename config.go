@@ -0,0 +1,183 @@
+package memoryguard
+
+import (
+	"log"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"time"
+)
+
+// config is a snapshot of the exported configuration fields of a MemoryGuard, taken when
+// Limit() is called, so that the caller mutating them afterward can't race with the Limit goro.
+type config struct {
+	name               string
+	cmdline            string
+	labels             map[string]string
+	limitSchedule      []ScheduleWindow
+	maxMappings        int
+	maxThreads         int
+	onThreads          func(threads, max int)
+	trackOverhead      bool
+	shouldKill         func(sample, limit int64, history []Sample) bool
+	skipLimitCheck     bool
+	immediateEnforce   bool
+	lowerLimitGrace    time.Duration
+	interval           time.Duration
+	intervalJitter     time.Duration
+	adaptInterval      bool
+	rng                *rand.Rand
+	debugOut           *log.Logger
+	errOut             *log.Logger
+	statsFrequency     time.Duration
+	killChan           chan struct{}
+	exitChan           chan struct{}
+	killChanOnStop     bool
+	killSignal         os.Signal
+	killConfirmTimeout time.Duration
+	killGroup          bool
+	preKillSignal      os.Signal
+	preKillDelay       time.Duration
+	usePidfd           bool
+	metric             Metric
+	includeChildren    bool
+	confirmWithPSS     bool
+	pssBand            float64
+	quiesceGC          bool
+	mappingWeights     map[MappingType]float64
+	avgWindow          time.Duration
+	compositeMetrics   []Metric
+	compositeMode      CompositeMode
+	procRoot           string
+	reportTopMappings  int
+	logFunc            LogFunc
+	minKillInterval    time.Duration
+	minAvailable       int64
+	psiFile            string
+	psiThreshold       float64
+	psiField           PSIField
+	psiCombined        bool
+	onDemand           bool
+	onSample           func(Sample)
+	onStats            func(GuardStats)
+	onRecover          func(int)
+	onExit             func(int)
+	onDrain            func()
+	drainFraction      float64
+	warnFraction       float64
+	onShutdown         func()
+	shutdownGrace      time.Duration
+	shutdownFunc       func()
+	onKillDenied       func(*KillEvent)
+	onBreach           func(*BreachContext) bool
+	profileLabels      bool
+	sampleTimeout      time.Duration
+	sampleBuffer       int
+	sampleDropOldest   bool
+	simulate           chan int64
+	growthWindow       time.Duration
+	growthFraction     float64
+	outlierFactor      float64
+	staleAfter         time.Duration
+	minProcessAge      time.Duration
+	systemdWatchdog    bool
+	tick               <-chan time.Time
+	clock              Clock
+	deadlineKill       bool
+	nokill             bool
+}
+
+// snapshot returns a new config from the current state of the fields.
+func (m *MemoryGuard) snapshot() *config {
+	c := config{
+		name:               m.Name,
+		interval:           m.Interval,
+		intervalJitter:     m.IntervalJitter,
+		adaptInterval:      m.AdaptInterval,
+		debugOut:           m.DebugOut,
+		errOut:             m.ErrOut,
+		statsFrequency:     m.StatsFrequency,
+		killChan:           m.KillChan,
+		exitChan:           m.ExitChan,
+		killChanOnStop:     m.CloseKillChanOnStop,
+		killSignal:         m.KillSignal,
+		killConfirmTimeout: m.KillConfirmTimeout,
+		killGroup:          m.KillGroup,
+		preKillSignal:      m.PreKillSignal,
+		preKillDelay:       m.PreKillDelay,
+		usePidfd:           m.UsePidfd,
+		metric:             m.Metric,
+		includeChildren:    m.IncludeChildren,
+		confirmWithPSS:     m.ConfirmWithPSS,
+		pssBand:            m.PSSBand,
+		quiesceGC:          m.QuiesceGC,
+		mappingWeights:     maps.Clone(m.MappingWeights),
+		labels:             maps.Clone(m.Labels),
+		limitSchedule:      slices.Clone(m.LimitSchedule),
+		maxMappings:        m.MaxMappings,
+		maxThreads:         m.MaxThreads,
+		onThreads:          m.OnThreads,
+		trackOverhead:      m.TrackOverhead,
+		shouldKill:         m.ShouldKill,
+		skipLimitCheck:     m.SkipLimitCheck,
+		immediateEnforce:   m.ImmediateEnforce,
+		lowerLimitGrace:    m.LowerLimitGrace,
+		avgWindow:          m.AvgWindow,
+		compositeMetrics:   slices.Clone(m.CompositeMetrics),
+		compositeMode:      m.CompositeMode,
+		procRoot:           m.ProcRoot,
+		reportTopMappings:  m.ReportTopMappings,
+		logFunc:            m.LogFunc,
+		minKillInterval:    m.MinKillInterval,
+		minAvailable:       m.MinAvailable,
+		psiFile:            m.PSIPath,
+		psiThreshold:       m.PSIThreshold,
+		psiField:           m.PSIField,
+		psiCombined:        m.PSICombined,
+		onDemand:           m.OnDemand,
+		onSample:           m.OnSample,
+		onStats:            m.OnStats,
+		onRecover:          m.OnRecover,
+		onExit:             m.OnExit,
+		onDrain:            m.OnDrain,
+		drainFraction:      m.DrainFraction,
+		warnFraction:       m.WarnFraction,
+		onShutdown:         m.OnShutdown,
+		shutdownGrace:      m.ShutdownGrace,
+		shutdownFunc:       m.ShutdownFunc,
+		onKillDenied:       m.OnKillDenied,
+		onBreach:           m.OnBreach,
+		profileLabels:      m.ProfileLabels,
+		sampleTimeout:      m.SampleTimeout,
+		sampleBuffer:       m.SampleBuffer,
+		sampleDropOldest:   m.SampleDropOldest,
+		simulate:           m.SimulateChan,
+		growthWindow:       m.GrowthWindow,
+		growthFraction:     m.GrowthFraction,
+		outlierFactor:      m.OutlierFactor,
+		staleAfter:         m.StaleAfter,
+		minProcessAge:      m.MinProcessAge,
+		systemdWatchdog:    m.SystemdWatchdog,
+		tick:               m.TickSource,
+		clock:              m.Clock,
+		deadlineKill:       m.DeadlineKill,
+		nokill:             m.nokill || m.DryRun,
+	}
+	if c.clock == nil {
+		c.clock = realClock{}
+	}
+	if c.procRoot == "" {
+		c.procRoot = DefaultProcRoot
+	}
+	return &c
+}
+
+// conf returns the config snapshotted by Limit(), or if it has not been called, a fresh one. That is a copy of
+// every field, so the accessors called before Limit() (e.g. name, now, procRoot) read the fields directly instead.
+func (m *MemoryGuard) conf() *config {
+	if c := m.cfg.Load(); c != nil {
+		return c
+	}
+	return m.snapshot()
+}
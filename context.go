@@ -0,0 +1,86 @@
+package memoryguard
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+)
+
+// StopReason is why the Limit goro stopped.
+type StopReason int32
+
+const (
+	// ReasonNone means the Limit goro has not stopped, or never started.
+	ReasonNone StopReason = iota
+	// ReasonCancel means Cancel (or CancelWait, or Close) was called.
+	ReasonCancel
+	// ReasonKill means the process was killed.
+	ReasonKill
+	// ReasonContextCancel means the context given to NewWithContext was cancelled.
+	ReasonContextCancel
+	// ReasonContextDeadline means the context given to NewWithContext passed its deadline.
+	ReasonContextDeadline
+	// ReasonZombie means the process exited, but hasn't been reaped by its parent, so there is nothing
+	// left to measure or kill.
+	ReasonZombie
+	// ReasonProcessExited means the process exited on its own, and has been reaped, so there is nothing
+	// left to measure or kill. A process that was never successfully sampled is not known to have exited,
+	// and its sampling errors are just errors.
+	ReasonProcessExited
+)
+
+// String returns the name of the StopReason
+func (r StopReason) String() string {
+	switch r {
+	case ReasonNone:
+		return "None"
+	case ReasonCancel:
+		return "Cancel"
+	case ReasonKill:
+		return "Kill"
+	case ReasonContextCancel:
+		return "ContextCancel"
+	case ReasonContextDeadline:
+		return "ContextDeadline"
+	case ReasonZombie:
+		return "Zombie"
+	case ReasonProcessExited:
+		return "ProcessExited"
+	}
+	return "StopReason(" + strconv.Itoa(int(r)) + ")"
+}
+
+// NewWithContext is New, but the Limit goro also stops when ctx is done, as if Cancel was called.
+// If ctx passes its deadline, and DeadlineKill is set, the process is killed on the way out.
+func NewWithContext(ctx context.Context, Process *os.Process, opts ...Option) *MemoryGuard {
+	m := New(Process, opts...)
+	m.ctx = ctx
+	return m
+}
+
+// StopReason returns why the Limit goro stopped, or ReasonNone if it hasn't.
+func (m *MemoryGuard) StopReason() StopReason {
+	return StopReason(m.stop.Load())
+}
+
+// contextDone records why ctx is done, and kills the process if it's the deadline and DeadlineKill is set.
+func (m *MemoryGuard) contextDone(name string) {
+	if !errors.Is(m.ctx.Err(), context.DeadlineExceeded) {
+		m.logf(LevelDebug, []any{"name", name}, "[%s] MemoryGuard Context Cancelled!\n", name)
+		m.stop.Store(int32(ReasonContextCancel))
+		return
+	}
+
+	m.logf(LevelDebug, []any{"name", name}, "[%s] MemoryGuard Context Deadline!\n", name)
+	m.stop.Store(int32(ReasonContextDeadline))
+	if !m.conf().deadlineKill {
+		return
+	}
+
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+	if m.killed.CompareAndSwap(false, true) {
+		m.breach(name, TriggerDeadline, m.lastPss.Load(), m.EffectiveLimit(), nil)
+	}
+}
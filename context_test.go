@@ -0,0 +1,74 @@
+package memoryguard
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_NewWithContext(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	us, _ := os.FindProcess(os.Getpid())
+
+	Convey("When a MemoryGuard's context is cancelled", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		mg := NewWithContext(ctx, us)
+		mg.SimulateChan = make(chan int64)
+		So(mg.Limit(1000), ShouldBeNil)
+		So(mg.StopReason(), ShouldEqual, ReasonNone)
+
+		cancel()
+		<-mg.Done()
+		So(mg.StopReason(), ShouldEqual, ReasonContextCancel)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+	})
+
+	Convey("When a MemoryGuard's context passes its deadline", t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		mg := NewWithContext(ctx, us)
+		mg.SimulateChan = make(chan int64)
+		So(mg.Limit(1000), ShouldBeNil)
+
+		<-mg.Done()
+		So(mg.StopReason(), ShouldEqual, ReasonContextDeadline)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+	})
+
+	Convey("When a MemoryGuard with DeadlineKill has its context pass its deadline", t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		mg := NewWithContext(ctx, us, WithDeadlineKill())
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+
+		<-mg.KillChan // wait for the kill
+		<-mg.Done()
+		So(mg.StopReason(), ShouldEqual, ReasonContextDeadline)
+		So(mg.Reason(), ShouldEqual, TriggerDeadline)
+		So(mg.Reason().String(), ShouldEqual, "Deadline")
+	})
+
+	Convey("When a MemoryGuard is cancelled, or kills, the StopReason says so", t, func() {
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		So(mg.Limit(1000), ShouldBeNil)
+		mg.CancelWait()
+		So(mg.StopReason(), ShouldEqual, ReasonCancel)
+		So(mg.StopReason().String(), ShouldEqual, "Cancel")
+
+		mg = New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		mg.SimulateChan <- 2000
+		<-mg.Done()
+		So(mg.StopReason(), ShouldEqual, ReasonKill)
+	})
+}
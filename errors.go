@@ -1,12 +1,70 @@
 package memoryguard
 
 const (
+	// CgroupFormatError is returned when a cgroup's memory.current cannot be parsed.
+	CgroupFormatError = Error("memory.current is not in the expected format")
+	// CgroupUnavailableError is returned by NewCgroupGuard if the path isn't a cgroup v2 with the memory controller.
+	CgroupUnavailableError = Error("not a cgroup v2 directory with memory.current")
+	// GaugeInvalidError is returned by AddGauge if the name is empty or the func is nil.
+	GaugeInvalidError = Error("AddGauge requires a name and a func")
 	// LimitZeroError is returned by Limit(int64) when the passed variable is <= 0.
 	LimitZeroError = Error("please call Limit(int64) with a value greater than zero")
 	// LimitNilProcessError is returned by Limit(int64) when the referenced *os.Process is nil.
 	LimitNilProcessError = Error("a Process has not been created and assigned, or is nil")
 	// LimitOnceError is returned by Limit(int64) if it has been called without error previously.
 	LimitOnceError = Error("Limit(int64) already called once")
+	// LimitNotSetError is returned by CheckNow() if Limit(int64) has not been called.
+	LimitNotSetError = Error("Limit(int64) has not been called")
+	// MaxMappingsMetricError is returned by Limit(int64) if MaxMappings is set with a Metric that doesn't count
+	// mappings (MetricRSSFast or MetricMaxRSS), or for a cgroup.
+	MaxMappingsMetricError = Error("MaxMappings requires a Metric that reads smaps")
+	// MaxRSSSelfError is returned by Limit(int64), and sampling, if the Metric is MetricMaxRSS and the process isn't us.
+	MaxRSSSelfError = Error("MetricMaxRSS can only guard this process")
+	// MeminfoFormatError is returned when MemAvailable (or MemTotal) cannot be found in /proc/meminfo.
+	MeminfoFormatError = Error("MemAvailable not found in meminfo")
+	// NoMatchError is returned by NewServiceGuard if no process matches the pattern.
+	NoMatchError = Error("no process matches the pattern")
+	// NSpidUnavailableError is returned when a process's status has no NSpid field (Linux < 4.1).
+	NSpidUnavailableError = Error("NSpid is not available in status")
+	// NSpidNotFoundError is returned when no process has the requested pid in the requested namespace.
+	NSpidNotFoundError = Error("no process with that pid in that namespace")
+	// OverheadUnavailableError is returned internally when per-thread usage can't be read for TrackOverhead.
+	OverheadUnavailableError = Error("per-thread usage is not available")
+	// PidfdUnavailableError is returned internally when UsePidfd is set where there are no pidfds (not Linux).
+	PidfdUnavailableError = Error("pidfds are only available on Linux")
+	// ProcfsUnavailableError is returned by Limit(int64) if there is no procfs mounted at ProcRoot.
+	ProcfsUnavailableError = Error("procfs is not available at ProcRoot")
+	// PSIFormatError is returned when a pressure file cannot be parsed.
+	PSIFormatError = Error("pressure stall information is not in the expected format")
+	// ResetRunningError is returned by Reset() if the Limit goro is still running.
+	ResetRunningError = Error("Reset() called while still running, Cancel first")
+	// RusageUnavailableError is returned when sampling MetricMaxRSS where getrusage's Maxrss isn't supported.
+	RusageUnavailableError = Error("getrusage maxrss is not available on this platform")
+	// SampleTimeoutError is the sampling error when a sample takes longer than SampleTimeout.
+	SampleTimeoutError = Error("sample timed out")
+	// SmapsFormatError is returned when a value in smaps cannot be parsed, or is negative.
+	SmapsFormatError = Error("smaps is not in the expected format")
+	// SmapsOverflowError is returned when the values in smaps sum to more than an int64 of Bytes.
+	SmapsOverflowError = Error("smaps values overflow")
+	// StatFormatError is returned when /proc/[pid]/stat or /proc/uptime cannot be parsed.
+	StatFormatError = Error("stat is not in the expected format")
+	// StatusFormatError is returned when /proc/[pid]/status has no Threads field, or it cannot be parsed.
+	StatusFormatError = Error("status is not in the expected format")
+	// StatmFormatError is returned when /proc/[pid]/statm cannot be parsed.
+	StatmFormatError = Error("statm is not in the expected format")
+	// ThresholdInvalidError is returned by AddThreshold if the fraction is <= 0, or the Action is nil.
+	ThresholdInvalidError = Error("please call AddThreshold with a fraction greater than zero and a non-nil Action")
+	// ThresholdAfterLimitError is returned by AddThreshold if Limit has already been called.
+	ThresholdAfterLimitError = Error("AddThreshold must be called before Limit")
+	// WaitTimeoutError is returned by WaitUntilBelow if the process doesn't drop below the value in time.
+	WaitTimeoutError = Error("timed out waiting for the process to drop below the value")
+	// KillGroupSelfError is set as KillError if KillGroup is set, and the process is in our own process group.
+	KillGroupSelfError = Error("refusing to kill our own process group")
+	// KillGroupSignalError is set as KillError if KillGroup is set, and KillSignal is not a syscall.Signal,
+	// or this isn't Unix.
+	KillGroupSignalError = Error("KillSignal must be a syscall.Signal to kill a process group")
+	// KillUnconfirmedError is set as KillError if KillConfirmTimeout is set, and the process could not be confirmed dead.
+	KillUnconfirmedError = Error("process could not be confirmed dead after kill")
 )
 
 // Error is an error type
@@ -0,0 +1,109 @@
+package memoryguard
+
+import (
+	"strconv"
+	"time"
+)
+
+// TriggerType is the condition that caused a MemoryGuard to act on its process.
+type TriggerType int
+
+const (
+	// TriggerNone means the MemoryGuard has not acted.
+	TriggerNone TriggerType = iota
+	// TriggerLimit means the sample exceeded the absolute limit set by Limit, or SetLimit.
+	TriggerLimit
+	// TriggerRelativeLimit means the sample exceeded the baseline plus the delta set by LimitRelative,
+	// which suggests a leak. Once SetLimit replaces it, and until ClearLimit, it is TriggerLimit.
+	TriggerRelativeLimit
+	// TriggerPSI means memory pressure stall information exceeded PSIThreshold.
+	TriggerPSI
+	// TriggerDeadline means the context given to NewWithContext passed its deadline, and DeadlineKill was set.
+	TriggerDeadline
+	// TriggerGrowth means the sample exceeded the rolling baseline by more than GrowthFraction,
+	// which suggests a leak.
+	TriggerGrowth
+	// TriggerMappings means the sample summed more mappings than MaxMappings, which suggests an mmap leak.
+	TriggerMappings
+	// TriggerThreads means the process had more Threads than MaxThreads, which suggests a thread leak.
+	TriggerThreads
+	// TriggerCancel means CancelKill was called.
+	TriggerCancel
+)
+
+// String returns the name of the TriggerType
+func (t TriggerType) String() string {
+	switch t {
+	case TriggerNone:
+		return "None"
+	case TriggerLimit:
+		return "Limit"
+	case TriggerRelativeLimit:
+		return "RelativeLimit"
+	case TriggerPSI:
+		return "PSI"
+	case TriggerDeadline:
+		return "Deadline"
+	case TriggerGrowth:
+		return "Growth"
+	case TriggerMappings:
+		return "Mappings"
+	case TriggerThreads:
+		return "Threads"
+	case TriggerCancel:
+		return "Cancel"
+	}
+	return "TriggerType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// KillEvent describes the action a MemoryGuard took on its process.
+type KillEvent struct {
+	// Trigger is the condition that fired
+	Trigger TriggerType
+	// Time is when the condition fired
+	Time time.Time
+	// Sample is the value that fired the condition
+	Sample int64
+	// Limit is the limit the Sample was compared against
+	Limit int64
+	// Metrics are the Metrics that were over the limit, if CompositeMetrics were set
+	Metrics []Metric
+	// Gauges are the values of the gauges from AddGauge at the time, by name, if any
+	Gauges map[string]float64
+	// Labels are the Labels of the MemoryGuard, if any
+	Labels map[string]string
+	// Cmdline is the command line of the process, with arguments separated by spaces, as read when Limit() was
+	// called, or empty if it couldn't be (e.g. a kernel thread, or a cgroup)
+	Cmdline string
+	// TopMappings are the largest mappings by Pss just prior to the kill, if ReportTopMappings was set
+	TopMappings []Mapping
+	// TopChild is the descendant of the process that contributed the most to the last sample, if IncludeChildren
+	// was set, and it has any, so the hog among them can be found
+	TopChild *ProcessUsage
+	// Confirmed is true if KillConfirmTimeout was set, and the process was confirmed dead
+	Confirmed bool
+	// Error is any error returned by the kill, same as KillError
+	Error error
+}
+
+// KillEvent returns the KillEvent describing why and how the process was killed,
+// or nil if it has not been killed. It is safe to call after KillChan is closed.
+func (m *MemoryGuard) KillEvent() *KillEvent {
+	return m.event.Load()
+}
+
+// Reason returns the TriggerType of the KillEvent, or TriggerNone if the process has not been killed.
+func (m *MemoryGuard) Reason() TriggerType {
+	if e := m.event.Load(); e != nil {
+		return e.Trigger
+	}
+	return TriggerNone
+}
+
+// WasKilled returns true if the limit was breached and acted upon (killed, shut down, or as OnBreach
+// decided), as KillChan being closed would say, but without having to race on it. It is false if the
+// Limit goro stopped for any other reason (see StopReason), or if the kill was denied and the
+// MemoryGuard degraded to ReportOnly.
+func (m *MemoryGuard) WasKilled() bool {
+	return m.killed.Load() && m.event.Load() != nil
+}
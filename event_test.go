@@ -0,0 +1,57 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardKillEvent(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard hasn't killed", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us)
+		mg.SimulateChan = sim
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+
+		Convey("there is no KillEvent or Reason", func() {
+			So(mg.KillEvent(), ShouldBeNil)
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+			So(mg.WasKilled(), ShouldBeFalse)
+		})
+
+		Convey("and an absolute limit is breached, the KillEvent says so", func() {
+			So(mg.Limit(1000), ShouldBeNil)
+			defer mg.Cancel()
+			sim <- 2000
+			<-mg.KillChan // wait for the kill
+
+			e := mg.KillEvent()
+			So(e, ShouldNotBeNil)
+			So(e.Trigger, ShouldEqual, TriggerLimit)
+			So(e.Sample, ShouldEqual, 2000)
+			So(e.Limit, ShouldEqual, 1000)
+			So(e.Time.IsZero(), ShouldBeFalse)
+			So(mg.Reason().String(), ShouldEqual, "Limit")
+			So(mg.WasKilled(), ShouldBeTrue)
+		})
+
+		Convey("and a relative limit is breached, the KillEvent says so", func() {
+			So(mg.LimitRelative(1000), ShouldBeNil)
+			defer mg.Cancel()
+			sim <- 500
+			sim <- 2000
+			<-mg.KillChan // wait for the kill
+
+			e := mg.KillEvent()
+			So(e, ShouldNotBeNil)
+			So(e.Trigger, ShouldEqual, TriggerRelativeLimit)
+			So(e.Limit, ShouldEqual, 1500)
+			So(mg.Reason().String(), ShouldEqual, "RelativeLimit")
+		})
+	})
+}
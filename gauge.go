@@ -0,0 +1,84 @@
+package memoryguard
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// gauges are the auxiliary gauges from AddGauge, in the order they were added.
+type gauges struct {
+	lock  sync.Mutex
+	names []string
+	fns   []func() float64
+}
+
+// AddGauge registers fn to be called, and its value logged as name alongside the PSS, every StatsFrequency,
+// and included in the KillEvent and BreachContext. This is intended for correlating memory with things like
+// request rate or queue depth while hunting a leak. A subsequent AddGauge with the same name replaces it.
+// It may be called at any time. Returns an error if name is empty or fn is nil.
+func (m *MemoryGuard) AddGauge(name string, fn func() float64) error {
+	if name == "" || fn == nil {
+		return GaugeInvalidError
+	}
+
+	m.gauges.lock.Lock()
+	defer m.gauges.lock.Unlock()
+
+	if i := slices.Index(m.gauges.names, name); i >= 0 {
+		m.gauges.fns[i] = fn
+		return nil
+	}
+	m.gauges.names = append(m.gauges.names, name)
+	m.gauges.fns = append(m.gauges.fns, fn)
+	return nil
+}
+
+// readGauges calls every gauge, returning their names and values in the order they were added.
+func (m *MemoryGuard) readGauges() ([]string, []float64) {
+	m.gauges.lock.Lock()
+	names, fns := slices.Clone(m.gauges.names), slices.Clone(m.gauges.fns)
+	m.gauges.lock.Unlock()
+
+	values := make([]float64, len(fns))
+	if len(fns) > 0 {
+		m.callback(func() {
+			for i := range fns {
+				values[i] = fns[i]()
+			}
+		})
+	}
+	return names, values
+}
+
+// gaugeMap returns the gauges by name, or nil if there are none, for events.
+func (m *MemoryGuard) gaugeMap() map[string]float64 {
+	return gaugeMapOf(m.readGauges())
+}
+
+// gaugeMapOf returns the names and values from readGauges by name, or nil if there are none.
+func gaugeMapOf(names []string, values []float64) map[string]float64 {
+	if len(names) == 0 {
+		return nil
+	}
+	gm := make(map[string]float64, len(names))
+	for i, name := range names {
+		gm[name] = values[i]
+	}
+	return gm
+}
+
+// gaugeLine returns the names and values from readGauges as " name=value" pairs, and as alternating keys
+// and values for LogFunc.
+func gaugeLine(names []string, values []float64) (string, []any) {
+	var (
+		b  strings.Builder
+		kv []any
+	)
+	for i, name := range names {
+		fmt.Fprintf(&b, " %s=%g", name, values[i])
+		kv = append(kv, name, values[i])
+	}
+	return b.String(), kv
+}
@@ -0,0 +1,61 @@
+package memoryguard
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardAddGauge(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard has gauges", t, func() {
+		var (
+			lock  sync.Mutex
+			stats []string
+		)
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithStatsFrequency(time.Millisecond), WithLogFunc(func(level, msg string, kv ...any) {
+			lock.Lock()
+			defer lock.Unlock()
+			if strings.Contains(msg, "Consecutive errors") {
+				stats = append(stats, msg)
+			}
+		}))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.AddGauge("", func() float64 { return 0 }), ShouldEqual, GaugeInvalidError)
+		So(mg.AddGauge("rps", nil), ShouldEqual, GaugeInvalidError)
+		So(mg.AddGauge("rps", func() float64 { return 1 }), ShouldBeNil)
+		So(mg.AddGauge("queue", func() float64 { return 7 }), ShouldBeNil)
+		So(mg.AddGauge("rps", func() float64 { return 42.5 }), ShouldBeNil) // replaced
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		Convey("they are in the stats line, in the order added", func() {
+			for {
+				lock.Lock()
+				n := len(stats)
+				lock.Unlock()
+				if n > 0 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			So(stats[0], ShouldEndWith, " rps=42.5 queue=7")
+		})
+
+		Convey("and in the KillEvent", func() {
+			mg.SimulateChan <- 2000
+			<-mg.KillChan // wait for the kill
+			So(mg.KillEvent().Gauges, ShouldResemble, map[string]float64{"rps": 42.5, "queue": 7})
+		})
+	})
+}
@@ -7,6 +7,7 @@ require (
 	github.com/fortytw2/leaktest v1.3.0
 	github.com/shirou/gopsutil/v4 v4.25.8
 	github.com/smartystreets/goconvey v1.8.1
+	golang.org/x/sys v0.35.0
 )
 
 require (
@@ -21,5 +22,4 @@ require (
 	github.com/tklauser/numcpus v0.10.0 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	golang.org/x/exp v0.0.0-20250819193227-8b4c13bb791b // indirect
-	golang.org/x/sys v0.35.0 // indirect
 )
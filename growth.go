@@ -0,0 +1,85 @@
+package memoryguard
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// rolling is a time-windowed buffer of samples, for the GrowthWindow minimum.
+type rolling struct {
+	lock    sync.Mutex
+	first   time.Time // when the first sample was added, so we know when the window is full
+	samples []Sample
+}
+
+// add adds the sample at now, drops samples older than span, and returns the minimum of the remainder,
+// and true if samples have been added for at least span.
+func (r *rolling) add(now time.Time, value int64, span time.Duration) (int64, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.first.IsZero() {
+		r.first = now
+	}
+	r.samples = append(r.samples, Sample{Time: now, Value: value})
+	return r.min(now, span), now.Sub(r.first) >= span
+}
+
+// minimum drops samples older than span, and returns the minimum of the remainder, or 0 if there are none.
+func (r *rolling) minimum(now time.Time, span time.Duration) int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.min(now, span)
+}
+
+// min does the work for add and minimum. Callers must hold lock.
+func (r *rolling) min(now time.Time, span time.Duration) int64 {
+	cutoff := now.Add(-span)
+	i := 0
+	for i < len(r.samples) && r.samples[i].Time.Before(cutoff) {
+		i++
+	}
+	r.samples = r.samples[i:]
+	if len(r.samples) == 0 {
+		return 0
+	}
+
+	low := r.samples[0].Value
+	for _, s := range r.samples[1:] {
+		low = min(low, s.Value)
+	}
+	return low
+}
+
+// RollingBaseline returns the minimum of the samples taken within the last GrowthWindow, or 0 if
+// GrowthWindow is unset, or there are no samples within it.
+func (m *MemoryGuard) RollingBaseline() int64 {
+	span := m.conf().growthWindow
+	if span <= 0 {
+		return 0
+	}
+	return m.rolling.minimum(m.now(), span)
+}
+
+// grown adds xss to the rolling window, and if GrowthWindow and GrowthFraction are set, and the window is
+// full, returns the growth limit, and true if xss is over it.
+func (m *MemoryGuard) grown(name string, xss int64) (int64, bool) {
+	c := m.conf()
+	if c.growthWindow <= 0 || c.growthFraction <= 0 {
+		return 0, false
+	}
+
+	base, full := m.rolling.add(m.now(), xss, c.growthWindow)
+	if !full || base <= 0 {
+		return 0, false
+	}
+	limit := base + int64(float64(base)*c.growthFraction)
+	if xss <= limit {
+		return limit, false
+	}
+	m.logf(LevelDebug, []any{"name", name, "pss", xss, "baseline", base, "limit", limit}, "[%s] MemoryGuard: %s is over %.0f%% growth from the rolling baseline of %s\n", name, humanity.ByteFormat(xss), c.growthFraction*100, humanity.ByteFormat(base))
+	return limit, true
+}
@@ -0,0 +1,89 @@
+package memoryguard
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// stepClock is a Clock whose Now moves only when told to, and whose After never fires.
+type stepClock struct {
+	now atomic.Int64
+}
+
+func (c *stepClock) Now() time.Time                       { return time.Unix(0, c.now.Load()) }
+func (c *stepClock) After(time.Duration) <-chan time.Time { return nil }
+func (c *stepClock) step(d time.Duration)                 { c.now.Add(int64(d)) }
+
+func Test_Rolling(t *testing.T) {
+	Convey("When samples are added to a rolling window", t, func() {
+		var (
+			r   rolling
+			now = time.Now()
+		)
+		low, full := r.add(now, 300, time.Minute)
+		So(low, ShouldEqual, 300)
+		So(full, ShouldBeFalse)
+		low, _ = r.add(now.Add(10*time.Second), 100, time.Minute)
+		So(low, ShouldEqual, 100)
+		low, full = r.add(now.Add(60*time.Second), 200, time.Minute)
+		So(low, ShouldEqual, 100)
+		So(full, ShouldBeTrue)
+
+		Convey("samples older than the span are dropped", func() {
+			low, _ = r.add(now.Add(75*time.Second), 250, time.Minute)
+			So(low, ShouldEqual, 200)
+			So(r.minimum(now.Add(200*time.Second), time.Minute), ShouldEqual, 0)
+		})
+	})
+}
+
+func Test_MemoryGuardGrowth(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard with a GrowthWindow has a footprint that shifts, then runs away", t, func() {
+		clock := &stepClock{}
+		clock.now.Store(time.Now().UnixNano())
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithClock(clock), WithGrowth(time.Minute, 0.5))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		// Samples every 10s. Growth before the window is full is ignored.
+		send := func(v int64) {
+			mg.SimulateChan <- v
+			clock.step(10 * time.Second)
+		}
+		send(1000)
+		send(5000)
+		send(1000)
+		for range 5 {
+			send(1200)
+		}
+		send(1200) // the value can't be received until the previous is processed
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+		So(mg.RollingBaseline(), ShouldEqual, 1200)
+
+		// The footprint legitimately shifts up, slowly: 5% a sample is only 34% a window
+		v := int64(1200)
+		for range 12 {
+			v += v / 20
+			send(v)
+		}
+		send(v)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+		So(mg.RollingBaseline(), ShouldBeGreaterThan, 1200)
+
+		// And runs away
+		send(10000)
+		<-mg.KillChan // wait for the kill
+		So(mg.Reason(), ShouldEqual, TriggerGrowth)
+		So(mg.KillEvent().Limit, ShouldBeLessThan, 10000)
+	})
+}
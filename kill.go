@@ -0,0 +1,307 @@
+package memoryguard
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// kills is a map of Name to the time.Time of the last kill, for MinKillInterval.
+var kills sync.Map
+
+// recordKill records now as the time of the last kill for name, if interval is set, and prunes kills older than
+// interval, so the map doesn't grow with every Name ever killed.
+func recordKill(name string, interval time.Duration, now time.Time) {
+	if interval <= 0 {
+		return
+	}
+	kills.Range(func(k, v any) bool {
+		if now.Sub(v.(time.Time)) >= interval {
+			kills.Delete(k)
+		}
+		return true
+	})
+	kills.Store(name, now)
+}
+
+// recentKill returns the time of the last kill for name, and true, if it was within interval of now.
+func recentKill(name string, interval time.Duration, now time.Time) (time.Time, bool) {
+	if interval <= 0 {
+		return time.Time{}, false
+	}
+	if last, ok := kills.Load(name); ok && now.Sub(last.(time.Time)) < interval {
+		return last.(time.Time), true
+	}
+	return time.Time{}, false
+}
+
+// KillsSuppressed returns the number of times a kill was suppressed due to MinKillInterval.
+func (m *MemoryGuard) KillsSuppressed() int64 {
+	return m.suppressed.Load()
+}
+
+// KillConfirmed returns true if KillConfirmTimeout was set, and the process was
+// confirmed dead after being killed.
+func (m *MemoryGuard) KillConfirmed() bool {
+	return m.confirmed.Load()
+}
+
+// CancelKill stops the Limit goro as CancelWait does, and then kills the process as a breach would (PreKillSignal,
+// KillSignal, KillConfirmTimeout, etc.) but without OnBreach, OnShutdown, or ShutdownFunc, for tearing it down
+// along with its guard. It is recorded as a KillEvent with TriggerCancel, and KillChan is closed. If the process
+// was already killed, it does nothing more than CancelWait. It must not be called from a callback (OnSample,
+// OnBreach, an Action, etc.). Returns the error from the kill, which is also KillError, or an error if there is
+// no process.
+func (m *MemoryGuard) CancelKill() error {
+	if m.proc == nil && m.cgroup == "" {
+		return LimitNilProcessError
+	}
+	// Keep the pidfd open until the kill, so it can't race with PID reuse
+	m.keepPidfd.Store(true)
+	defer func() {
+		m.pidfd.close()
+		m.keepPidfd.Store(false)
+	}()
+	m.CancelWait()
+
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+	if !m.killed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	name, c := m.name(), m.conf()
+	m.logf(LevelDebug, []any{"name", name, "trigger", TriggerCancel}, "[%s] MemoryGuard Cancelled, killing the process\n", name)
+	if !c.nokill {
+		m.KillError = m.kill()
+	}
+	m.event.Store(&KillEvent{
+		Trigger:   TriggerCancel,
+		Time:      m.now(),
+		Sample:    m.lastPss.Load(),
+		Limit:     m.EffectiveLimit(),
+		Gauges:    m.gaugeMap(),
+		Labels:    maps.Clone(c.labels),
+		Cmdline:   c.cmdline,
+		Confirmed: m.confirmed.Load(),
+		Error:     m.KillError,
+	})
+	if errors.Is(m.KillError, os.ErrPermission) {
+		// As breach, we can't kill it, so it isn't killed.
+		m.killed.Store(false)
+		return m.KillError
+	}
+
+	recordKill(name, c.minKillInterval, m.now())
+	select {
+	case <-c.killChan:
+		// CloseKillChanOnStop already closed it
+	default:
+		close(c.killChan)
+	}
+	return m.KillError
+}
+
+// kill signals the process with PreKillSignal if set, waiting PreKillDelay, then with KillSignal (or os.Kill),
+// and if KillConfirmTimeout is set, waits for it to die, escalating to os.Kill if needed.
+func (m *MemoryGuard) kill() error {
+	c := m.conf()
+	sig := c.killSignal
+	if sig == nil {
+		sig = os.Kill
+	}
+
+	if c.preKillSignal != nil {
+		// Diagnostics only, so an error here shouldn't stop the kill
+		if err := m.signal(c.preKillSignal); err != nil {
+			m.logf(LevelError, []any{"pid", m.Pid(), "signal", c.preKillSignal, "error", err}, "MemoryGuard process %d pre-kill %s Error: %s\n", m.Pid(), c.preKillSignal, err)
+		} else {
+			sleep(c.clock, c.preKillDelay)
+		}
+	}
+
+	if c.killGroup && m.cgroup == "" {
+		return m.killGroup(c, sig)
+	}
+
+	if err := m.signal(sig); err != nil {
+		return err
+	}
+
+	if c.killConfirmTimeout <= 0 {
+		// Not confirming
+		return nil
+	}
+
+	if m.waitGone(c, c.killConfirmTimeout) {
+		m.confirmed.Store(true)
+		return nil
+	}
+
+	if sig != os.Kill {
+		// Gentle didn't take, escalate.
+		m.logf(LevelError, []any{"pid", m.Pid(), "signal", sig}, "MemoryGuard process %d did not die after %s, escalating to %s\n", m.Pid(), sig, os.Kill)
+		if err := m.signal(os.Kill); err != nil {
+			return err
+		}
+		if m.waitGone(c, c.killConfirmTimeout) {
+			m.confirmed.Store(true)
+			return nil
+		}
+	}
+
+	return KillUnconfirmedError
+}
+
+// quiesced returns true if QuiesceGC is set, we are guarding ourselves, and after forcing a garbage
+// collection that returns memory to the OS, we are no longer over max.
+func (m *MemoryGuard) quiesced(name string, max int64) bool {
+	c := m.conf()
+	if !c.quiesceGC || c.simulate != nil || m.Pid() != os.Getpid() {
+		return false
+	}
+
+	debug.FreeOSMemory()
+	xss, err := m.sample()
+	if err != nil || xss > max {
+		return false
+	}
+	m.storePss(xss)
+	m.logf(LevelDebug, []any{"name", name, "pss", xss, "limit", max}, "[%s] MemoryGuard: %s Limit %s after GC, not killing\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max))
+	return true
+}
+
+// act ends the process as a breach does: via ShutdownFunc if we're guarding ourselves, or else OnShutdown if
+// it exits within ShutdownGrace, or else by killing it. Returns the error from the kill, if any.
+func (m *MemoryGuard) act() error {
+	if m.selfShutdown() {
+		// we're leaving on our own
+		return nil
+	} else if m.shutdown() {
+		// it left on its own
+		return nil
+	} else if m.conf().nokill {
+		// don't kill it
+		return nil
+	}
+	return m.kill()
+}
+
+// selfShutdown calls ShutdownFunc in its own goro if it is set, and the process is our own, returning true
+// if it did.
+func (m *MemoryGuard) selfShutdown() bool {
+	c := m.conf()
+	if c.shutdownFunc == nil || m.cgroup != "" || m.proc.Pid != os.Getpid() {
+		return false
+	}
+	go c.shutdownFunc()
+	return true
+}
+
+// shutdown calls OnShutdown if it is set, and waits up to ShutdownGrace for the process to exit,
+// returning true if it did.
+func (m *MemoryGuard) shutdown() bool {
+	c := m.conf()
+	if c.onShutdown == nil {
+		return false
+	}
+
+	go c.onShutdown()
+	if c.shutdownGrace <= 0 || c.simulate != nil {
+		return false
+	}
+	return m.waitGone(c, c.shutdownGrace)
+}
+
+// waitGone waits up to timeout for the process, or every process in the cgroup, to be gone, returning true if it is.
+func (m *MemoryGuard) waitGone(c *config, timeout time.Duration) bool {
+	if m.cgroup != "" {
+		return waitCgroupEmpty(c.clock, m.cgroup, timeout)
+	}
+	return waitGone(c.clock, c.procRoot, m.proc.Pid, timeout)
+}
+
+// signal sends sig to the process.
+func (m *MemoryGuard) signal(sig os.Signal) error {
+	if m.signaller != nil {
+		return m.signaller(sig)
+	} else if m.cgroup != "" {
+		return signalCgroup(m.cgroup, sig)
+	} else if sent, err := m.pidfd.signal(sig); sent {
+		return err
+	}
+	return m.proc.Signal(sig)
+}
+
+// ReportOnly returns true if the MemoryGuard has lost permission to kill the process, and
+// has degraded to only reporting breaches.
+func (m *MemoryGuard) ReportOnly() bool {
+	return m.reportOnly.Load()
+}
+
+// waitGone polls procfs until the pid is gone (or a zombie), returning true, or
+// the timeout passes on clock, returning false. We poll rather than Wait() because we may
+// not own the process, and if we do, the caller may well be Wait()ing on it.
+func waitGone(clock Clock, root string, pid int, timeout time.Duration) bool {
+	deadline := clock.Now().Add(timeout)
+	for {
+		if isGone(root, pid) {
+			return true
+		} else if clock.Now().After(deadline) {
+			return false
+		}
+		sleep(clock, 10*time.Millisecond)
+	}
+}
+
+// isGone returns true if the pid no longer exists in procfs, or is a zombie.
+func isGone(root string, pid int) bool {
+	state, err := procState(root, pid)
+	if err != nil {
+		return true
+	}
+	return state == 'Z' || state == 'X'
+}
+
+// isZombie returns true if the pid has exited, but has not been reaped by its parent.
+func isZombie(root string, pid int) bool {
+	state, err := procState(root, pid)
+	return err == nil && state == 'Z'
+}
+
+// reaped returns true if the process is no longer in procfs at all, or the cgroup no longer exists.
+func (m *MemoryGuard) reaped(c *config) bool {
+	if m.cgroup != "" {
+		_, err := os.Stat(m.cgroup)
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	return isReaped(c.procRoot, m.proc.Pid)
+}
+
+// isReaped returns true if the pid is no longer in procfs at all.
+func isReaped(root string, pid int) bool {
+	_, err := os.Stat(procPath(root, pid, ""))
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// procState takes a procfs root and a pid, and returns the state (e.g. 'R', 'S', 'Z') from stat,
+// 0 if it can't be found, or an error if stat can't be read.
+func procState(root string, pid int) (byte, error) {
+	stat, err := os.ReadFile(procPath(root, pid, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// The state follows the parenthesized comm, which may itself contain spaces or parens.
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 || i+2 >= len(stat) {
+		return 0, nil
+	}
+	return stat[i+2], nil
+}
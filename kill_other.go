@@ -0,0 +1,10 @@
+//go:build !unix
+
+package memoryguard
+
+import "os"
+
+// killGroup returns KillGroupSignalError, as there are no process groups to signal here.
+func (m *MemoryGuard) killGroup(c *config, sig os.Signal) error {
+	return KillGroupSignalError
+}
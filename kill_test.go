@@ -0,0 +1,430 @@
+package memoryguard
+
+import (
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardKillConfirm(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When an external command runs, and we kill it gently with confirmation", t, func() {
+		cmd := exec.Command("sleep", "30")
+		So(cmd.Start(), ShouldBeNil)
+		mg := New(cmd.Process)
+		mg.Interval = time.Millisecond
+		mg.KillSignal = syscall.SIGTERM
+		mg.KillConfirmTimeout = 2 * time.Second
+		mg.Limit(1024) // 1KB
+
+		Convey("it should die and be confirmed", func() {
+			defer mg.Cancel()
+			<-mg.KillChan // wait for the kill
+			So(mg.KillError, ShouldBeNil)
+			So(mg.KillConfirmed(), ShouldBeTrue)
+			So(cmd.Wait().Error(), ShouldEqual, "signal: terminated")
+		})
+	})
+
+	Convey("When an external command that ignores SIGTERM runs, and we kill it gently with confirmation", t, func() {
+		cmd := exec.Command("bash", "-c", `trap "" TERM; exec sleep 30`)
+		So(cmd.Start(), ShouldBeNil)
+		time.Sleep(100 * time.Millisecond) // let the trap get set
+		mg := New(cmd.Process)
+		mg.Interval = time.Millisecond
+		mg.KillSignal = syscall.SIGTERM
+		mg.KillConfirmTimeout = 500 * time.Millisecond
+		mg.Limit(1024) // 1KB
+
+		Convey("it should be escalated to a kill, and be confirmed", func() {
+			defer mg.Cancel()
+			<-mg.KillChan // wait for the kill
+			So(mg.KillError, ShouldBeNil)
+			So(mg.KillConfirmed(), ShouldBeTrue)
+			So(cmd.Wait().Error(), ShouldEqual, "signal: killed")
+		})
+	})
+}
+
+func Test_MemoryGuardMinKillInterval(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard with a MinKillInterval kills a process", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us)
+		mg.Name = "stormy"
+		mg.MinKillInterval = time.Hour
+		mg.SimulateChan = sim
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		sim <- 2000
+		<-mg.KillChan // wait for the kill
+
+		Convey("a new MemoryGuard with the same Name won't kill within the interval", func() {
+			sim2 := make(chan int64)
+			mg2 := New(us)
+			mg2.Name = "stormy"
+			mg2.MinKillInterval = time.Hour
+			mg2.SimulateChan = sim2
+			mg2.nokill = true
+			So(mg2.Limit(1000), ShouldBeNil)
+			defer mg2.Cancel()
+
+			sim2 <- 2000
+			sim2 <- 2000 // the second value can't be received until the first is processed
+			So(mg2.KillsSuppressed(), ShouldBeGreaterThanOrEqualTo, 1)
+			So(mg2.running.Load(), ShouldBeTrue)
+
+			var killed bool
+			select {
+			case <-mg2.KillChan:
+				killed = true
+			default:
+			}
+			So(killed, ShouldBeFalse)
+		})
+	})
+}
+
+func Test_RecordKill(t *testing.T) {
+	Convey("When kills are recorded with a MinKillInterval, older ones are pruned", t, func() {
+		now := time.Now()
+		recordKill("pruned-old", time.Minute, now.Add(-time.Hour))
+		recordKill("pruned-new", time.Minute, now)
+		defer kills.Delete("pruned-new")
+
+		_, ok := kills.Load("pruned-old")
+		So(ok, ShouldBeFalse)
+		_, ok = recentKill("pruned-new", time.Minute, now)
+		So(ok, ShouldBeTrue)
+	})
+
+	Convey("When a kill is recorded without a MinKillInterval, it isn't kept", t, func() {
+		recordKill("unrecorded", 0, time.Now())
+		_, ok := kills.Load("unrecorded")
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func Test_MemoryGuardKillDenied(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard isn't permitted to kill the process", t, func() {
+		var denied = make(chan *KillEvent, 1)
+
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us)
+		mg.SimulateChan = sim
+		mg.OnKillDenied = func(e *KillEvent) { denied <- e }
+		mg.signaller = func(os.Signal) error { return syscall.EPERM }
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("it escalates once, degrades to report-only, and keeps guarding", func() {
+			sim <- 2000
+			e := <-denied
+			So(e.Error, ShouldEqual, syscall.EPERM)
+			So(mg.KillError, ShouldEqual, syscall.EPERM)
+			So(mg.ReportOnly(), ShouldBeTrue)
+
+			sim <- 2000
+			sim <- 500 // the third value can't be received until the second is processed
+			So(mg.running.Load(), ShouldBeTrue)
+			So(len(denied), ShouldEqual, 0)
+			So(mg.WasKilled(), ShouldBeFalse)
+
+			var killed bool
+			select {
+			case <-mg.KillChan:
+				killed = true
+			default:
+			}
+			So(killed, ShouldBeFalse)
+		})
+	})
+}
+
+func Test_MemoryGuardKillGroup(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When an external process group runs, where one child ignores SIGTERM, and we kill it gently with confirmation", t, func() {
+		cmd := exec.Command("bash", "-c", `sleep 30 & (trap "" TERM; exec sleep 30) & wait`)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		So(cmd.Start(), ShouldBeNil)
+		time.Sleep(100 * time.Millisecond) // let the children start
+		pgid := cmd.Process.Pid
+		So(groupMembers(DefaultProcRoot, pgid), ShouldHaveLength, 3)
+
+		mg := New(cmd.Process, WithKillGroup(), WithKillSignal(syscall.SIGTERM, 500*time.Millisecond))
+		mg.Interval = time.Millisecond
+		mg.Limit(1024) // 1KB
+
+		Convey("the whole group should die, and be confirmed", func() {
+			defer mg.Cancel()
+			<-mg.KillChan // wait for the kill
+			So(mg.KillError, ShouldBeNil)
+			So(mg.KillConfirmed(), ShouldBeTrue)
+			So(groupMembers(DefaultProcRoot, pgid), ShouldBeEmpty)
+			cmd.Wait()
+		})
+	})
+
+	Convey("When a MemoryGuard is in KillGroup mode on us", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us, WithKillGroup())
+		mg.SimulateChan = sim
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("it refuses to kill our own group", func() {
+			sim <- 2000
+			<-mg.KillChan // wait for the kill
+			So(mg.KillError, ShouldEqual, KillGroupSelfError)
+		})
+	})
+}
+
+func Test_MemoryGuardDrain(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard has OnDrain and OnShutdown", t, func() {
+		var calls = make(chan string, 2)
+
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us, WithDrain(func() { calls <- "drain" }, 0.5, func() { calls <- "shutdown" }, 0))
+		mg.SimulateChan = sim
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("they are called in order, as the usage crosses their levels", func() {
+			sim <- 400
+			sim <- 600
+			So(<-calls, ShouldEqual, "drain")
+
+			sim <- 2000
+			<-mg.KillChan // wait for the kill
+			So(<-calls, ShouldEqual, "shutdown")
+		})
+	})
+
+	Convey("When an external command runs, and exits on its own after OnShutdown", t, func() {
+		cmd := exec.Command("sleep", "30")
+		So(cmd.Start(), ShouldBeNil)
+		mg := New(cmd.Process, WithDrain(nil, 0, func() { cmd.Process.Signal(syscall.SIGTERM) }, 2*time.Second))
+		mg.Interval = time.Millisecond
+		mg.Limit(1024) // 1KB
+
+		Convey("it isn't killed", func() {
+			defer mg.Cancel()
+			<-mg.KillChan // wait for the "kill"
+			So(mg.KillError, ShouldBeNil)
+			So(cmd.Wait().Error(), ShouldEqual, "signal: terminated")
+		})
+	})
+}
+
+func Test_MemoryGuardShutdownFunc(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard guarding us has a ShutdownFunc", t, func() {
+		var (
+			calls     = make(chan string, 2)
+			signalled atomic.Bool
+		)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithShutdownFunc(func() { calls <- "self" }), WithDrain(nil, 0, func() { calls <- "shutdown" }, 0))
+		mg.SimulateChan = make(chan int64)
+		mg.signaller = func(os.Signal) error { signalled.Store(true); return nil } // just in case
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("it is called instead of killing us", func() {
+			mg.SimulateChan <- 2000
+			<-mg.KillChan // wait for the "kill"
+			So(<-calls, ShouldEqual, "self")
+			So(len(calls), ShouldEqual, 0)
+			So(signalled.Load(), ShouldBeFalse)
+			So(mg.WasKilled(), ShouldBeTrue)
+		})
+	})
+
+	Convey("When a simulating MemoryGuard guarding us has a ShutdownFunc, and an OnBreach that kills", t, func() {
+		var (
+			calls     = make(chan string, 1)
+			killErr   = make(chan error, 1)
+			signalled atomic.Bool
+		)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithShutdownFunc(func() { calls <- "self" }), WithOnBreach(func(bc *BreachContext) bool {
+			killErr <- bc.Kill()
+			return true
+		}))
+		mg.SimulateChan = make(chan int64)
+		mg.signaller = func(os.Signal) error { signalled.Store(true); return nil } // just in case
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("it is called instead of killing us", func() {
+			mg.SimulateChan <- 2000
+			<-mg.KillChan // wait for the "kill"
+			So(<-killErr, ShouldBeNil)
+			So(<-calls, ShouldEqual, "self")
+			So(signalled.Load(), ShouldBeFalse)
+		})
+	})
+
+	Convey("When a MemoryGuard guarding an external command has a ShutdownFunc", t, func() {
+		var called atomic.Bool
+
+		cmd := exec.Command("sleep", "30")
+		So(cmd.Start(), ShouldBeNil)
+		mg := New(cmd.Process, WithInterval(time.Millisecond), WithShutdownFunc(func() { called.Store(true) }))
+		So(mg.Limit(1024), ShouldBeNil) // 1KB
+
+		Convey("it is ignored, and the command is killed", func() {
+			defer mg.Cancel()
+			<-mg.KillChan // wait for the kill
+			So(cmd.Wait().Error(), ShouldEqual, "signal: killed")
+			So(called.Load(), ShouldBeFalse)
+			So(mg.KillEvent().Cmdline, ShouldEqual, "sleep 30")
+		})
+	})
+}
+
+func Test_MemoryGuardPidfd(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When an external command runs, and we kill it via a pidfd", t, func() {
+		cmd := exec.Command("sleep", "30")
+		So(cmd.Start(), ShouldBeNil)
+		mg := New(cmd.Process, WithPidfd())
+		mg.Interval = time.Millisecond
+		mg.KillSignal = syscall.SIGTERM
+		So(mg.Limit(1024), ShouldBeNil) // 1KB
+
+		Convey("it should die, and the pidfd should be released", func() {
+			<-mg.KillChan // wait for the kill
+			So(mg.KillError, ShouldBeNil)
+			So(cmd.Wait().Error(), ShouldEqual, "signal: terminated")
+			<-mg.done // the limiter exits after a kill
+			So(mg.pidfd.open, ShouldBeFalse)
+		})
+	})
+
+	Convey("When an external command runs, and we CancelKill it with a pidfd", t, func() {
+		cmd := exec.Command("sleep", "30")
+		So(cmd.Start(), ShouldBeNil)
+		tick := make(chan time.Time)
+		mg := New(cmd.Process, WithPidfd(), WithTickSource(tick))
+		mg.KillSignal = syscall.SIGTERM
+		So(mg.Limit(1024*1024*1024), ShouldBeNil) // 1GB
+
+		Convey("it is killed via the pidfd, which is then released", func() {
+			samples := mg.Samples()
+			tick <- time.Now()
+			<-samples // the Limit goro is done with the process, until the next tick
+			// Beyond the largest pid_max, so signalling the PID instead would kill nothing
+			mg.proc, _ = os.FindProcess(1<<22 + 1)
+
+			So(mg.CancelKill(), ShouldBeNil)
+			So(cmd.Wait().Error(), ShouldEqual, "signal: terminated")
+			So(mg.pidfd.open, ShouldBeFalse)
+		})
+	})
+}
+
+func Test_MemoryGuardPreKillSignal(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard with a PreKillSignal breaches", t, func() {
+		var (
+			signals []os.Signal
+			times   []time.Time
+		)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithPreKillSignal(syscall.SIGUSR1, 20*time.Millisecond))
+		mg.SimulateChan = make(chan int64)
+		mg.signaller = func(sig os.Signal) error {
+			signals = append(signals, sig)
+			times = append(times, time.Now())
+			return nil
+		}
+		So(mg.Limit(1000), ShouldBeNil)
+
+		mg.SimulateChan <- 2000
+		<-mg.KillChan // wait for the kill
+		So(signals, ShouldResemble, []os.Signal{syscall.SIGUSR1, os.Kill})
+		So(times[1].Sub(times[0]), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+	})
+}
+
+func Test_MemoryGuardQuiesceGC(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a self-guarding MemoryGuard with QuiesceGC is over its limit only due to garbage", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithQuiesceGC(), WithOnDemand())
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+
+		garbage := make([]byte, 32*1024*1024)
+		for i := range garbage {
+			garbage[i] = 1 // touch it, so it's resident
+		}
+		before, err := getPss(DefaultProcRoot, os.Getpid())
+		So(err, ShouldBeNil)
+		garbage = nil
+		So(mg.Limit(before-16*1024*1024), ShouldBeNil)
+
+		pss, over, err := mg.CheckNow()
+		So(err, ShouldBeNil)
+		So(pss, ShouldBeGreaterThan, before-16*1024*1024)
+		So(over, ShouldBeFalse)
+		So(mg.PSS(), ShouldBeLessThan, before-16*1024*1024)
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+	})
+}
+
+func Test_MemoryGuardCancelKill(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When an external command runs well under its Limit, and we CancelKill", t, func() {
+		cmd := exec.Command("sleep", "30")
+		So(cmd.Start(), ShouldBeNil)
+		mg := New(cmd.Process)
+		mg.Interval = time.Millisecond
+		mg.CloseKillChanOnStop = true
+		So(mg.Limit(1024*1024*1024), ShouldBeNil) // 1GB
+
+		Convey("the guard stops, and the process is killed and recorded as such", func() {
+			So(mg.CancelKill(), ShouldBeNil)
+			So(cmd.Wait().Error(), ShouldEqual, "signal: killed")
+			<-mg.KillChan
+			So(mg.StopReason(), ShouldEqual, ReasonCancel)
+			So(mg.Reason(), ShouldEqual, TriggerCancel)
+			So(mg.Reason().String(), ShouldEqual, "Cancel")
+			So(mg.WasKilled(), ShouldBeTrue)
+			So(mg.KillEvent().Cmdline, ShouldEqual, "sleep 30")
+
+			Convey("and another CancelKill does nothing", func() {
+				So(mg.CancelKill(), ShouldBeNil)
+			})
+		})
+	})
+}
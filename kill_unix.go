@@ -0,0 +1,102 @@
+//go:build unix
+
+package memoryguard
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// killGroup signals the process group of the process with sig, and if KillConfirmTimeout is set,
+// waits for every member to die, escalating only the survivors to os.Kill if needed.
+func (m *MemoryGuard) killGroup(c *config, sig os.Signal) error {
+	pgid, err := syscall.Getpgid(m.proc.Pid)
+	if err != nil {
+		return err
+	} else if pgid == syscall.Getpgrp() {
+		// That's us!
+		return KillGroupSelfError
+	}
+
+	ssig, ok := sig.(syscall.Signal)
+	if !ok {
+		return KillGroupSignalError
+	}
+	if err := syscall.Kill(-pgid, ssig); err != nil {
+		return err
+	}
+
+	if c.killConfirmTimeout <= 0 {
+		// Not confirming
+		return nil
+	}
+
+	if waitGroupGone(c.clock, c.procRoot, pgid, c.killConfirmTimeout) {
+		m.confirmed.Store(true)
+		return nil
+	}
+
+	if ssig != syscall.SIGKILL {
+		// Gentle didn't take for some, escalate just those.
+		for _, pid := range groupMembers(c.procRoot, pgid) {
+			m.logf(LevelError, []any{"pid", pid, "pgid", pgid, "signal", sig}, "MemoryGuard process %d in group %d did not die after %s, escalating to %s\n", pid, pgid, sig, os.Kill)
+			syscall.Kill(pid, syscall.SIGKILL) // it may have died in the meantime, so ignore errors
+		}
+		if waitGroupGone(c.clock, c.procRoot, pgid, c.killConfirmTimeout) {
+			m.confirmed.Store(true)
+			return nil
+		}
+	}
+
+	return KillUnconfirmedError
+}
+
+// groupMembers returns the pids of all of the live (non-zombie) processes in the process group pgid.
+func groupMembers(root string, pgid int) []int {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	var pids []int
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(procPath(root, pid, "stat"))
+		if err != nil {
+			continue
+		}
+		// state ppid pgrp follow the parenthesized comm, which may itself contain spaces or parens.
+		i := bytes.LastIndexByte(stat, ')')
+		if i < 0 {
+			continue
+		}
+		fields := bytes.Fields(stat[i+1:])
+		if len(fields) < 3 || fields[0][0] == 'Z' || fields[0][0] == 'X' {
+			continue
+		}
+		if g, err := strconv.Atoi(string(fields[2])); err == nil && g == pgid {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// waitGroupGone polls procfs until the process group pgid has no live members, returning true, or
+// the timeout passes on clock, returning false.
+func waitGroupGone(clock Clock, root string, pgid int, timeout time.Duration) bool {
+	deadline := clock.Now().Add(timeout)
+	for {
+		if len(groupMembers(root, pgid)) == 0 {
+			return true
+		} else if clock.Now().After(deadline) {
+			return false
+		}
+		sleep(clock, 10*time.Millisecond)
+	}
+}
@@ -0,0 +1,58 @@
+package memoryguard
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Latency is a summary of how long sampling has taken.
+type Latency struct {
+	// Last is the duration of the most recent sample
+	Last time.Duration
+	// Avg is the mean duration of all samples
+	Avg time.Duration
+	// Max is the duration of the longest sample
+	Max time.Duration
+	// Count is the number of samples taken
+	Count int64
+}
+
+// latency is the goro-safe accumulator behind Latency
+type latency struct {
+	last  atomic.Int64
+	max   atomic.Int64
+	total atomic.Int64
+	count atomic.Int64
+}
+
+// record adds d to the latency.
+func (l *latency) record(d time.Duration) {
+	l.last.Store(int64(d))
+	l.total.Add(int64(d))
+	l.count.Add(1)
+	for {
+		max := l.max.Load()
+		if int64(d) <= max || l.max.CompareAndSwap(max, int64(d)) {
+			return
+		}
+	}
+}
+
+// summary returns a Latency for the current state.
+func (l *latency) summary() Latency {
+	lat := Latency{
+		Last:  time.Duration(l.last.Load()),
+		Max:   time.Duration(l.max.Load()),
+		Count: l.count.Load(),
+	}
+	if lat.Count > 0 {
+		lat.Avg = time.Duration(l.total.Load() / lat.Count)
+	}
+	return lat
+}
+
+// SampleLatency returns a summary of how long each sample of the process's memory usage
+// has taken in the Limit() goro, which is useful for tuning Interval.
+func (m *MemoryGuard) SampleLatency() Latency {
+	return m.latency.summary()
+}
@@ -0,0 +1,49 @@
+package memoryguard
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// LevelDebug is the level passed to LogFunc for events otherwise sent to DebugOut
+	LevelDebug = "debug"
+	// LevelError is the level passed to LogFunc for events otherwise sent to ErrOut
+	LevelError = "error"
+)
+
+// LogFunc is a function that receives log events from a MemoryGuard, allowing any logging
+// library to be adapted. level is LevelDebug or LevelError, msg is the formatted message,
+// and kv are alternating keys and values relevant to the event (e.g. "name", "bob", "pss", 1234),
+// followed by the Labels, if any, sorted by key.
+type LogFunc func(level, msg string, kv ...any)
+
+// logf formats and emits a log event to LogFunc if set, or else to DebugOut or ErrOut per the level.
+func (m *MemoryGuard) logf(level string, kv []any, format string, args ...any) {
+	c := m.conf()
+	msg := fmt.Sprintf(format, args...)
+	if c.logFunc != nil {
+		c.logFunc(level, strings.TrimSuffix(msg, "\n"), append(kv, labelKV(c.labels)...)...)
+		return
+	}
+
+	if level == LevelError {
+		c.errOut.Print(msg)
+	} else {
+		c.debugOut.Print(msg)
+	}
+}
+
+// labelKV returns the labels as alternating keys and values, sorted by key, or nil if there are none.
+func labelKV(labels map[string]string) []any {
+	if len(labels) == 0 {
+		return nil
+	}
+	kv := make([]any, 0, 2*len(labels))
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		kv = append(kv, k, labels[k])
+	}
+	return kv
+}
@@ -0,0 +1,179 @@
+package memoryguard
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardLogFunc(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with a LogFunc", t, func() {
+		var (
+			lock   sync.Mutex
+			levels = make(map[string]int)
+			msgs   []string
+			alert  []any
+		)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.Name = "bob"
+		mg.Labels = map[string]string{"tenant": "acme", "region": "moon"}
+		mg.Interval = time.Millisecond
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		mg.LogFunc = func(level, msg string, kv ...any) {
+			lock.Lock()
+			defer lock.Unlock()
+			levels[level]++
+			msgs = append(msgs, msg)
+			if level == LevelError {
+				alert = kv
+			}
+		}
+		mg.Limit(1024) // 1KB
+
+		Convey("it receives the debug and error events", func() {
+			<-mg.KillChan // wait for the kill
+			<-mg.Done()
+
+			lock.Lock()
+			defer lock.Unlock()
+			So(levels[LevelDebug], ShouldBeGreaterThanOrEqualTo, 2) // Running, Leaving
+			So(levels[LevelError], ShouldEqual, 1)                  // ALERT
+			So(msgs, ShouldContain, "MemoryGuard Limiter Leaving!")
+			So(alert[len(alert)-4:], ShouldResemble, []any{"region", "moon", "tenant", "acme"})
+			So(mg.KillEvent().Labels, ShouldResemble, map[string]string{"tenant": "acme", "region": "moon"})
+		})
+	})
+}
+
+func Test_MemoryGuardStatsFrequency(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with a long Interval, and a short StatsFrequency", t, func() {
+		var (
+			lock  sync.Mutex
+			stats int
+		)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.Interval = time.Hour
+		mg.StatsFrequency = 10 * time.Millisecond
+		mg.LogFunc = func(level, msg string, kv ...any) {
+			lock.Lock()
+			defer lock.Unlock()
+			if strings.Contains(msg, "Consecutive errors") {
+				stats++
+			}
+		}
+		mg.Limit(400 * 1024 * 1024) // we won't actually hit this, right?
+
+		Convey("stats are emitted on their own schedule", func() {
+			time.Sleep(100 * time.Millisecond)
+			mg.CancelWait()
+
+			lock.Lock()
+			defer lock.Unlock()
+			So(stats, ShouldBeGreaterThanOrEqualTo, 3)
+		})
+	})
+}
+
+func Test_MemoryGuardOnStats(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with an OnStats", t, func() {
+		stats := make(chan GuardStats, 1)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithName("bob"), WithInterval(time.Hour), WithLabels(map[string]string{"tenant": "acme"}),
+			WithOnStats(func(s GuardStats) {
+				select {
+				case stats <- s:
+				default:
+				}
+			}))
+		mg.StatsFrequency = 10 * time.Millisecond
+		So(mg.AddGauge("queue", func() float64 { return 42 }), ShouldBeNil)
+		So(mg.Limit(400*1024*1024), ShouldBeNil) // we won't actually hit this, right?
+		defer mg.CancelWait()
+
+		Convey("it is called with the stats, on their own schedule", func() {
+			s := <-stats
+			So(s.Name, ShouldEqual, "bob")
+			So(s.Limit, ShouldEqual, 400*1024*1024)
+			So(s.Errors, ShouldEqual, 0)
+			So(s.Time.IsZero(), ShouldBeFalse)
+			So(s.Gauges, ShouldResemble, map[string]float64{"queue": 42})
+			So(s.Labels, ShouldResemble, map[string]string{"tenant": "acme"})
+		})
+
+		Convey("the Limit is the EffectiveLimit, including any BoostLimit", func() {
+			mg.BoostLimit(1024, time.Hour)
+			var s GuardStats
+			for range 5 { // the first few may have been taken before the boost
+				if s = <-stats; s.Limit != 400*1024*1024 {
+					break
+				}
+			}
+			So(s.Limit, ShouldEqual, 400*1024*1024+1024)
+		})
+	})
+
+	Convey("When a monitor is running on us with an OnStats", t, func() {
+		stats := make(chan GuardStats, 1)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg, err := NewMonitor(us, time.Hour, func(Sample) {}, WithStatsFrequency(10*time.Millisecond),
+			WithOnStats(func(s GuardStats) {
+				select {
+				case stats <- s:
+				default:
+				}
+			}))
+		So(err, ShouldBeNil)
+		defer mg.CancelWait()
+
+		Convey("the Limit is 0, as there is none", func() {
+			So((<-stats).Limit, ShouldEqual, 0)
+		})
+	})
+}
+
+func Test_MemoryGuardSubscribe(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with subscribers to its stats", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithInterval(time.Hour))
+		mg.StatsFrequency = time.Millisecond
+		slow, _ := mg.Subscribe(0)
+		gone, unsubscribe := mg.Subscribe(4)
+		So(mg.Limit(400*1024*1024), ShouldBeNil) // we won't actually hit this, right?
+
+		Convey("an unsubscribed one is closed, and a slow one doesn't block, but gets the latest", func() {
+			unsubscribe()
+			unsubscribe() // harmless
+			for range gone {
+			}
+
+			first := <-slow
+			time.Sleep(20 * time.Millisecond)
+			So(len(slow), ShouldEqual, 1)
+			latest := <-slow
+			So(latest.Time.After(first.Time), ShouldBeTrue)
+
+			mg.CancelWait()
+			for range slow {
+			}
+		})
+	})
+}
@@ -0,0 +1,179 @@
+package memoryguard
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Manager is a collection of MemoryGuards, for those watching a fleet of processes who want a birds-eye view of them.
+// Adding a MemoryGuard to a Manager doesn't change how it is configured or armed.
+type Manager struct {
+	lock    sync.RWMutex
+	members map[*MemoryGuard]*member
+	added   uint64 // the number of Adds, for ordering
+	total   atomic.Int64
+	peak    atomic.Int64
+}
+
+// member is how a MemoryGuard was added to the Manager, for CancelAllOrdered, and its part of the total.
+type member struct {
+	priority int
+	seq      uint64
+	counted  atomic.Int64 // the sample of the MemoryGuard in the total
+}
+
+// NewManager returns an empty Manager.
+func NewManager() *Manager {
+	return &Manager{
+		members: make(map[*MemoryGuard]*member),
+	}
+}
+
+// Add adds the MemoryGuard to the Manager with a Priority of 0. A MemoryGuard may only be in one
+// Manager at a time, and adding it to another moves it there.
+func (g *Manager) Add(m *MemoryGuard) {
+	g.AddWithPriority(m, 0)
+}
+
+// AddWithPriority is Add, with a priority for CancelAllOrdered. Adding a member again updates its priority,
+// and makes it the most recently added.
+func (g *Manager) AddWithPriority(m *MemoryGuard, priority int) {
+	if old := m.manager.Swap(g); old != nil && old != g {
+		old.remove(m)
+	}
+
+	g.lock.Lock()
+	g.added++
+	mem := &member{priority: priority, seq: g.added}
+	if old := g.members[m]; old != nil {
+		mem.counted.Store(old.counted.Load())
+	}
+	g.members[m] = mem
+	g.lock.Unlock()
+	g.sampled(m)
+}
+
+// CancelAll cancels every member at once, and waits until they are all done. They remain members.
+func (g *Manager) CancelAll() {
+	guards := g.ordered()
+	for _, m := range guards {
+		m.Cancel()
+	}
+	for _, m := range guards {
+		m.CancelWait()
+	}
+}
+
+// CancelAllOrdered cancels every member in turn, waiting for each to be done before cancelling the next,
+// so dependent processes stop in the right sequence: highest priority first, and among equals, the
+// most recently added first. They remain members.
+func (g *Manager) CancelAllOrdered() {
+	for _, m := range g.ordered() {
+		m.CancelWait()
+	}
+}
+
+// ordered returns the members in CancelAllOrdered order.
+func (g *Manager) ordered() []*MemoryGuard {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	guards := make([]*MemoryGuard, 0, len(g.members))
+	for m := range g.members {
+		guards = append(guards, m)
+	}
+	slices.SortFunc(guards, func(a, b *MemoryGuard) int {
+		ma, mb := g.members[a], g.members[b]
+		if c := cmp.Compare(mb.priority, ma.priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(mb.seq, ma.seq)
+	})
+	return guards
+}
+
+// Remove removes the MemoryGuard from the Manager, if it is a member. It is not cancelled.
+func (g *Manager) Remove(m *MemoryGuard) {
+	if m.manager.CompareAndSwap(g, nil) {
+		g.remove(m)
+	}
+}
+
+// remove deletes m from the members.
+func (g *Manager) remove(m *MemoryGuard) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if mem := g.members[m]; mem != nil {
+		g.total.Add(-mem.counted.Load())
+		delete(g.members, m)
+	}
+}
+
+// Len returns the number of MemoryGuards in the Manager.
+func (g *Manager) Len() int {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return len(g.members)
+}
+
+// TotalPSS returns the sum of the most recent sample from each member, in Bytes.
+func (g *Manager) TotalPSS() int64 {
+	return g.total.Load()
+}
+
+// PeakTotalPSS returns the highest TotalPSS seen since the Manager was created, in Bytes.
+// It is updated every time a member takes a sample.
+func (g *Manager) PeakTotalPSS() int64 {
+	return g.peak.Load()
+}
+
+// Guarded returns the number of members whose Limit goro is currently running.
+func (g *Manager) Guarded() int {
+	return g.count(func(m *MemoryGuard) bool {
+		return m.running.Load()
+	})
+}
+
+// Killed returns the number of members that have killed their process (see WasKilled).
+func (g *Manager) Killed() int {
+	return g.count(func(m *MemoryGuard) bool {
+		return m.WasKilled()
+	})
+}
+
+// count returns the number of members for which f is true.
+func (g *Manager) count(f func(*MemoryGuard) bool) int {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var n int
+	for m := range g.members {
+		if f(m) {
+			n++
+		}
+	}
+	return n
+}
+
+// sampled is called when member m takes a sample, and updates the total with the difference from its last,
+// and the peak.
+func (g *Manager) sampled(m *MemoryGuard) {
+	g.lock.RLock()
+	mem := g.members[m]
+	if mem == nil {
+		g.lock.RUnlock()
+		return
+	}
+	xss := m.lastPss.Load()
+	total := g.total.Add(xss - mem.counted.Swap(xss))
+	g.lock.RUnlock()
+
+	for {
+		peak := g.peak.Load()
+		if total <= peak || g.peak.CompareAndSwap(peak, total) {
+			return
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package memoryguard
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Manager(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a Manager has two simulating MemoryGuards", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		simA, simB := make(chan int64), make(chan int64)
+		a, b := New(us, WithName("a")), New(us, WithName("b"))
+		a.SimulateChan, b.SimulateChan = simA, simB
+		a.nokill, b.nokill = true, true // set internal tunable to not actually kill ourselves.
+
+		g := NewManager()
+		g.Add(a)
+		g.Add(b)
+		So(g.Len(), ShouldEqual, 2)
+		So(g.Guarded(), ShouldEqual, 0)
+
+		So(a.Limit(10000), ShouldBeNil)
+		So(b.Limit(10000), ShouldBeNil)
+		So(g.Guarded(), ShouldEqual, 2)
+
+		simA <- 3000
+		simA <- 3000 // the second value can't be received until the first is processed
+		simB <- 4000
+		simB <- 2000
+		simB <- 2000
+		So(g.TotalPSS(), ShouldEqual, 5000)
+		So(g.PeakTotalPSS(), ShouldEqual, 7000)
+		So(g.Killed(), ShouldEqual, 0)
+
+		simB <- 20000
+		<-b.KillChan // wait for the kill
+		<-b.Done()
+		So(g.Killed(), ShouldEqual, 1)
+		So(g.Guarded(), ShouldEqual, 1)
+		So(g.PeakTotalPSS(), ShouldEqual, 23000)
+
+		g.Remove(b)
+		So(g.Len(), ShouldEqual, 1)
+		So(g.TotalPSS(), ShouldEqual, 3000)
+		So(g.PeakTotalPSS(), ShouldEqual, 23000)
+		a.CancelWait()
+		So(g.Guarded(), ShouldEqual, 0)
+	})
+
+	Convey("When a MemoryGuard is added to a second Manager, it moves", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		g1, g2 := NewManager(), NewManager()
+		g1.Add(mg)
+		g2.Add(mg)
+		So(g1.Len(), ShouldEqual, 0)
+		So(g2.Len(), ShouldEqual, 1)
+		g1.Remove(mg) // not a member, so nothing happens
+		So(g2.Len(), ShouldEqual, 1)
+	})
+
+	Convey("When a member samples, is moved, and is Reset, the total follows it", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us)
+		mg.SimulateChan = sim
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		mg.OnBreach = func(*BreachContext) bool { return false }
+
+		g1, g2 := NewManager(), NewManager()
+		g1.Add(mg)
+		So(mg.Limit(10000), ShouldBeNil)
+		sim <- 3000
+		sim <- 3000
+		So(g1.TotalPSS(), ShouldEqual, 3000)
+
+		g2.Add(mg)
+		So(g1.TotalPSS(), ShouldEqual, 0)
+		So(g2.TotalPSS(), ShouldEqual, 3000)
+
+		sim <- 20000 // declined by OnBreach
+		sim <- 20000
+		So(g2.TotalPSS(), ShouldEqual, 20000)
+		So(g2.PeakTotalPSS(), ShouldEqual, 20000)
+		So(g2.Killed(), ShouldEqual, 0)
+
+		mg.CancelWait()
+		mg.Reset()
+		So(g2.TotalPSS(), ShouldEqual, 0)
+		g2.Remove(mg)
+		So(g2.TotalPSS(), ShouldEqual, 0)
+	})
+}
+
+func Test_ManagerCancelAll(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a Manager has simulating MemoryGuards with priorities", t, func() {
+		var (
+			lock    sync.Mutex
+			stopped []string
+		)
+		logf := func(level, msg string, kv ...any) {
+			if strings.HasSuffix(msg, "Cancelled!") {
+				lock.Lock()
+				defer lock.Unlock()
+				stopped = append(stopped, kv[1].(string))
+			}
+		}
+
+		us, _ := os.FindProcess(os.Getpid())
+		g := NewManager()
+		for _, m := range []struct {
+			name     string
+			priority int
+		}{{"db", 0}, {"cache", 0}, {"web", 10}, {"worker", 5}} {
+			mg := New(us, WithName(m.name), WithLogFunc(logf))
+			mg.SimulateChan = make(chan int64)
+			So(mg.Limit(10000), ShouldBeNil)
+			g.AddWithPriority(mg, m.priority)
+		}
+		So(g.Guarded(), ShouldEqual, 4)
+
+		Convey("CancelAllOrdered stops them by priority, then most recently added first", func() {
+			g.CancelAllOrdered()
+			So(g.Guarded(), ShouldEqual, 0)
+			So(g.Len(), ShouldEqual, 4)
+			So(stopped, ShouldResemble, []string{"web", "worker", "cache", "db"})
+		})
+
+		Convey("CancelAll stops them all", func() {
+			g.CancelAll()
+			So(g.Guarded(), ShouldEqual, 0)
+			So(stopped, ShouldHaveLength, 4)
+		})
+	})
+}
@@ -0,0 +1,13 @@
+package memoryguard
+
+// MappingCount returns the number of mappings summed by the last sample, or 0 if the Metric isn't per-mapping
+// (e.g. MetricRSSFast), or nothing has been sampled. The RSS samples of ConfirmWithPSS leave it as it was.
+func (m *MemoryGuard) MappingCount() int {
+	return int(m.mappings.Load())
+}
+
+// tooManyMappings returns true if MaxMappings is set, and the last sample summed more mappings than it.
+func (m *MemoryGuard) tooManyMappings() bool {
+	n := m.conf().maxMappings
+	return n > 0 && m.MappingCount() > n
+}
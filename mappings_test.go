@@ -0,0 +1,38 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardMaxMappings(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with a MaxMappings we're over", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us, WithTickSource(tick), WithMaxMappings(1))
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.MappingCount(), ShouldEqual, 0)
+		So(mg.Limit(400*1024*1024*1024), ShouldBeNil) // we won't actually hit this, right?
+		defer mg.Cancel()
+
+		Convey("it acts on the mappings, even though we're under the limit", func() {
+			tick <- time.Now()
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerMappings)
+			So(mg.Reason().String(), ShouldEqual, "Mappings")
+			So(mg.MappingCount(), ShouldBeGreaterThan, 1)
+		})
+	})
+
+	Convey("When a MemoryGuard has a MaxMappings, and a Metric that doesn't count mappings, it refuses", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		So(New(us, WithMaxMappings(1), WithMetric(MetricRSSFast)).Limit(1024), ShouldEqual, MaxMappingsMetricError)
+		So(New(us, WithMaxMappings(1), WithMetric(MetricMaxRSS)).Limit(1024), ShouldEqual, MaxMappingsMetricError)
+	})
+}
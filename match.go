@@ -0,0 +1,225 @@
+package memoryguard
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MatchGuard guards every process whose command line matches a pattern, discovering them by scanning
+// procfs, so PIDs needn't be known up front. New matches join automatically, and exited ones drop off.
+type MatchGuard struct {
+	pattern    *regexp.Regexp
+	max        int64
+	perProcess bool
+	opts       []Option
+	root       string
+	clock      Clock
+
+	lock    sync.Mutex
+	guards  map[int]*MemoryGuard
+	manager *Manager
+
+	cancel chan struct{}
+	done   chan struct{}
+}
+
+// NewMatchGuard takes a pattern to match command lines (with arguments separated by spaces) against, the
+// max usage (in Bytes), whether it applies to each process or to all of them combined, how often to scan
+// procfs, and optional Options for each process's MemoryGuard, and returns a running MatchGuard.
+// Per-process, each match is guarded by its own MemoryGuard, as usual. Combined, each match is monitored,
+// and when their total exceeds max at a scan, the largest is killed as configured. Close it when done.
+func NewMatchGuard(pattern *regexp.Regexp, max int64, perProcess bool, scan time.Duration, opts ...Option) (*MatchGuard, error) {
+	if max <= 0 {
+		return nil, LimitZeroError
+	}
+
+	m := applied(opts)
+	g := &MatchGuard{
+		pattern:    pattern,
+		max:        max,
+		perProcess: perProcess,
+		opts:       opts,
+		root:       m.procRoot(),
+		clock:      m.clock(),
+		guards:     make(map[int]*MemoryGuard),
+		manager:    NewManager(),
+		cancel:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if !procfsAvailable(g.root) {
+		return nil, ProcfsUnavailableError
+	}
+
+	g.scan()
+	go g.run(scan)
+	return g, nil
+}
+
+// Matched returns the PIDs of the processes currently matched, in order.
+func (g *MatchGuard) Matched() []int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	pids := make([]int, 0, len(g.guards))
+	for pid := range g.guards {
+		pids = append(pids, pid)
+	}
+	slices.Sort(pids)
+	return pids
+}
+
+// TotalPSS returns the sum of the most recent samples of the matched processes, in Bytes.
+func (g *MatchGuard) TotalPSS() int64 {
+	return g.manager.TotalPSS()
+}
+
+// Close stops scanning, and cancels every MemoryGuard. It always returns nil.
+func (g *MatchGuard) Close() error {
+	select {
+	case <-g.cancel:
+		// already closed
+	default:
+		close(g.cancel)
+	}
+	<-g.done
+
+	g.lock.Lock()
+	gone := slices.Collect(maps.Values(g.guards))
+	clear(g.guards)
+	g.lock.Unlock()
+	g.drop(gone)
+	return nil
+}
+
+// drop cancels and removes each of gone, which must already be out of guards. Callers must not hold lock, as
+// a callback of one of them (e.g. OnSample) may be waiting on it, which would deadlock CancelWait.
+func (g *MatchGuard) drop(gone []*MemoryGuard) {
+	for _, m := range gone {
+		m.CancelWait()
+		g.manager.Remove(m)
+	}
+}
+
+// run scans every interval until Close.
+func (g *MatchGuard) run(interval time.Duration) {
+	defer close(g.done)
+
+	for {
+		select {
+		case <-g.cancel:
+			return
+		case <-g.clock.After(interval):
+			g.scan()
+		}
+	}
+}
+
+// scan reconciles the guarded set with the matching processes, and if combined, enforces the limit.
+func (g *MatchGuard) scan() {
+	matches := g.matches()
+
+	var gone []*MemoryGuard
+	g.lock.Lock()
+	for pid, m := range g.guards {
+		if _, ok := matches[pid]; !ok || isGone(g.root, pid) {
+			gone = append(gone, m)
+			delete(g.guards, pid)
+		}
+	}
+	g.lock.Unlock()
+	g.drop(gone)
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for pid := range matches {
+		if _, ok := g.guards[pid]; ok {
+			continue
+		}
+		proc, err := os.FindProcess(pid)
+		if err != nil {
+			continue
+		}
+		m := New(proc, g.opts...)
+		if g.perProcess {
+			err = m.Limit(g.max)
+		} else {
+			err = m.arm(monitorLimit, 0)
+		}
+		if err != nil {
+			m.logf(LevelError, []any{"name", m.name(), "error", err}, "[%s] MemoryGuard match Error: %s\n", m.name(), err)
+			continue
+		}
+		g.guards[pid] = m
+		g.manager.Add(m)
+	}
+
+	if !g.perProcess {
+		g.enforce()
+	}
+}
+
+// enforce kills the largest live process if the total is over max. Callers must hold lock.
+func (g *MatchGuard) enforce() {
+	total := g.manager.TotalPSS()
+	if total <= g.max {
+		return
+	}
+
+	var largest *MemoryGuard
+	for _, m := range g.guards {
+		if !m.killed.Load() && (largest == nil || m.lastPss.Load() > largest.lastPss.Load()) {
+			largest = m
+		}
+	}
+	if largest == nil {
+		return
+	}
+
+	largest.checkLock.Lock()
+	defer largest.checkLock.Unlock()
+	if largest.killed.CompareAndSwap(false, true) {
+		largest.breach(largest.name(), TriggerLimit, total, g.max, nil)
+	}
+}
+
+// matches scans procfs for processes whose command lines match, other than our own.
+func (g *MatchGuard) matches() map[int]struct{} {
+	matches := make(map[int]struct{})
+	for pid := range matchCmdlines(g.root, g.pattern) {
+		matches[pid] = struct{}{}
+	}
+	return matches
+}
+
+// matchCmdlines takes a procfs root and a pattern, and returns the command lines (with arguments separated
+// by spaces) of the processes that match it, other than our own, by PID.
+func matchCmdlines(root string, pattern *regexp.Regexp) map[int]string {
+	matches := make(map[int]string)
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return matches
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		cmdline, err := os.ReadFile(procPath(root, pid, "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		cmdline = bytes.ReplaceAll(bytes.TrimRight(cmdline, "\x00"), []byte{0}, []byte(" "))
+		if pattern.Match(cmdline) {
+			matches[pid] = string(cmdline)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,91 @@
+package memoryguard
+
+import (
+	"os/exec"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MatchGuard(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MatchGuard guards processes by cmdline against a combined limit", t, func() {
+		pattern := regexp.MustCompile(`^sleep 31\.4159$`)
+		cmds := []*exec.Cmd{exec.Command("sleep", "31.4159"), exec.Command("sleep", "31.4159")}
+		for _, cmd := range cmds {
+			So(cmd.Start(), ShouldBeNil)
+		}
+
+		Convey("with a roomy limit, both are matched, and a new one joins", func() {
+			g, err := NewMatchGuard(pattern, 1024*1024*1024, false, time.Millisecond, WithInterval(time.Millisecond))
+			So(err, ShouldBeNil)
+			So(g.Matched(), ShouldResemble, []int{min(cmds[0].Process.Pid, cmds[1].Process.Pid), max(cmds[0].Process.Pid, cmds[1].Process.Pid)})
+
+			third := exec.Command("sleep", "31.4159")
+			So(third.Start(), ShouldBeNil)
+			for len(g.Matched()) < 3 {
+				time.Sleep(time.Millisecond)
+			}
+
+			third.Process.Kill()
+			third.Wait()
+			for len(g.Matched()) > 2 {
+				time.Sleep(time.Millisecond)
+			}
+			So(g.Close(), ShouldBeNil)
+
+			for _, cmd := range cmds {
+				cmd.Process.Kill()
+				cmd.Wait()
+			}
+		})
+
+		Convey("with a tiny limit, they are killed, largest first", func() {
+			g, err := NewMatchGuard(pattern, 1024, false, time.Millisecond, WithInterval(time.Millisecond))
+			So(err, ShouldBeNil)
+			defer g.Close()
+
+			for _, cmd := range cmds {
+				So(cmd.Wait().Error(), ShouldEqual, "signal: killed")
+			}
+		})
+	})
+
+	Convey("When a MatchGuard's OnSample asks what is Matched, a process exiting doesn't deadlock it", t, func() {
+		var guard atomic.Pointer[MatchGuard]
+		onSample := func(Sample) {
+			if g := guard.Load(); g != nil {
+				g.Matched()
+			}
+		}
+		pattern := regexp.MustCompile(`^sleep 27\.1828$`)
+		cmds := []*exec.Cmd{exec.Command("sleep", "27.1828"), exec.Command("sleep", "27.1828")}
+		for _, cmd := range cmds {
+			So(cmd.Start(), ShouldBeNil)
+		}
+
+		g, err := NewMatchGuard(pattern, 1024*1024*1024, true, time.Millisecond, WithInterval(time.Millisecond), WithOnSample(onSample))
+		So(err, ShouldBeNil)
+		guard.Store(g)
+		So(g.Matched(), ShouldHaveLength, 2)
+
+		cmds[0].Process.Kill()
+		cmds[0].Wait()
+		for len(g.Matched()) > 1 {
+			time.Sleep(time.Millisecond)
+		}
+		So(g.Close(), ShouldBeNil)
+		cmds[1].Process.Kill()
+		cmds[1].Wait()
+	})
+
+	Convey("When a MatchGuard is made with a zero limit, it refuses", t, func() {
+		_, err := NewMatchGuard(regexp.MustCompile(`.`), 0, true, time.Second)
+		So(err, ShouldEqual, LimitZeroError)
+	})
+}
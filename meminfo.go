@@ -0,0 +1,102 @@
+package memoryguard
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// getMemAvailable takes a procfs root, and returns MemAvailable from meminfo in Bytes, or an error.
+func getMemAvailable(root string) (int64, error) {
+	return getMeminfo(root, "MemAvailable")
+}
+
+// getMemTotal takes a procfs root, and returns MemTotal from meminfo in Bytes, or an error.
+func getMemTotal(root string) (int64, error) {
+	return getMeminfo(root, "MemTotal")
+}
+
+// getMeminfo takes a procfs root and a key, and returns its value from meminfo in Bytes, or an error.
+func getMeminfo(root, key string) (int64, error) {
+	f, err := os.Open(filepath.Join(root, "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	pfx := []byte(key + ":")
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Bytes()
+		if bytes.HasPrefix(line, pfx) {
+			fields := bytes.Fields(line[len(pfx):])
+			if len(fields) < 1 {
+				return 0, MeminfoFormatError
+			}
+			size, err := strconv.ParseInt(string(fields[0]), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return size * 1024, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, MeminfoFormatError
+}
+
+// underPressure returns true if MinAvailable is unset, or system MemAvailable is below it,
+// along with the MemAvailable (or 0 if unknown). If MemAvailable cannot be read, we err on
+// the side of reporting pressure.
+func (m *MemoryGuard) underPressure() (bool, int64) {
+	c := m.conf()
+	if c.minAvailable <= 0 {
+		return true, 0
+	}
+
+	avail, err := getMemAvailable(c.procRoot)
+	if err != nil {
+		m.logf(LevelError, []any{"name", m.name(), "error", err}, "[%s] MemoryGuard meminfo Error: %s\n", m.name(), err)
+		return true, 0
+	}
+	return avail < c.minAvailable, avail
+}
+
+// memoryCeiling returns the most memory the process could ever use, being the lower of MemTotal and the
+// memory.max of its cgroups, and where that comes from, or 0 if neither can be read.
+func (m *MemoryGuard) memoryCeiling(c *config) (int64, string) {
+	var (
+		ceiling int64
+		source  string
+	)
+	if total, err := getMemTotal(c.procRoot); err == nil {
+		ceiling, source = total, "MemTotal"
+	}
+
+	cgroup := m.cgroup
+	if cgroup == "" {
+		cgroup = procCgroup(c.procRoot, m.proc.Pid)
+	}
+	if cgroup == "" {
+		return ceiling, source
+	}
+	if l := cgroupMemoryMax(cgroup); l > 0 && (ceiling == 0 || l < ceiling) {
+		ceiling, source = l, "cgroup memory.max"
+	}
+	return ceiling, source
+}
+
+// checkReachable logs a warning if max is more than the memoryCeiling, as it can then never be reached.
+func (m *MemoryGuard) checkReachable(c *config, max int64) {
+	ceiling, source := m.memoryCeiling(c)
+	if ceiling > 0 && max > ceiling {
+		m.logf(LevelError, []any{"name", c.name, "limit", max, "ceiling", ceiling, "source", source}, "[%s] MemoryGuard WARNING! Limit %s is more than the %s %s, so can never be reached\n", c.name, humanity.ByteFormat(max), source, humanity.ByteFormat(ceiling))
+	}
+}
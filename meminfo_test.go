@@ -0,0 +1,104 @@
+package memoryguard
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_GetMemAvailable(t *testing.T) {
+	Convey("When MemAvailable is read from the real meminfo", t, func() {
+		avail, err := getMemAvailable(DefaultProcRoot)
+		Convey("it doesn't return an error, and is positive", func() {
+			So(err, ShouldBeNil)
+			So(avail, ShouldBeGreaterThan, 0)
+		})
+	})
+
+	Convey("When MemAvailable is read from a meminfo without it", t, func() {
+		root := t.TempDir()
+		os.WriteFile(filepath.Join(root, "meminfo"), []byte("MemTotal: 1024 kB\n"), 0644)
+		_, err := getMemAvailable(root)
+		Convey("it returns an error", func() {
+			So(err, ShouldEqual, MeminfoFormatError)
+		})
+	})
+}
+
+func Test_MemoryGuardMinAvailable(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard has a MinAvailable floor", t, func() {
+		root := t.TempDir()
+		meminfo := filepath.Join(root, "meminfo")
+		os.WriteFile(meminfo, []byte("MemTotal: 4096 kB\nMemAvailable: 2048 kB\n"), 0644)
+
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us)
+		mg.ProcRoot = root
+		mg.SimulateChan = sim
+		mg.MinAvailable = 1024 * 1024 // 1MB
+		mg.nokill = true              // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("being over the limit with plenty available doesn't kill, but once available drops below the floor, it does", func() {
+			sim <- 2000
+			sim <- 500 // the second value can't be received until the first is processed
+			So(mg.running.Load(), ShouldBeTrue)
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+
+			os.WriteFile(meminfo, []byte("MemTotal: 4096 kB\nMemAvailable: 512 kB\n"), 0644)
+			sim <- 2000
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerLimit)
+		})
+	})
+}
+
+func Test_MemoryGuardUnreachableLimit(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When simulating MemoryGuards are given Limits against a small MemTotal", t, func() {
+		root := t.TempDir()
+		os.WriteFile(filepath.Join(root, "meminfo"), []byte("MemTotal: 4096 kB\nMemAvailable: 2048 kB\n"), 0644)
+
+		var (
+			lock sync.Mutex
+			kvs  [][]any
+		)
+		logf := func(level, msg string, kv ...any) {
+			lock.Lock()
+			defer lock.Unlock()
+			if level == LevelError {
+				kvs = append(kvs, kv)
+			}
+		}
+
+		us, _ := os.FindProcess(os.Getpid())
+		for _, l := range []struct {
+			max  int64
+			skip bool
+		}{{1024 * 1024 * 1024, false}, {1024 * 1024 * 1024, true}, {1024, false}} {
+			mg := New(us)
+			mg.ProcRoot = root
+			mg.SimulateChan = make(chan int64)
+			mg.SkipLimitCheck = l.skip
+			mg.LogFunc = logf
+			So(mg.Limit(l.max), ShouldBeNil)
+			mg.CancelWait()
+		}
+
+		Convey("only the one that can't be reached, and isn't skipped, is warned about", func() {
+			lock.Lock()
+			defer lock.Unlock()
+			So(kvs, ShouldHaveLength, 1)
+			So(kvs[0][2:], ShouldResemble, []any{"limit", int64(1024 * 1024 * 1024), "ceiling", int64(4096 * 1024), "source", "MemTotal"})
+		})
+	})
+}
@@ -0,0 +1,73 @@
+package memoryguardtest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake memoryguard.Clock, whose time only moves when Advance is called, so time-dependent
+// behaviors can be tested deterministically, and without sleeping.
+type Clock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+// waiter is a pending After.
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewClock returns a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the current time of the Clock.
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// After returns a channel that receives the time once the Clock has been advanced by d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// Advance moves the Clock forward by d, firing every After that has come due.
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.c <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// Waiters returns the number of Afters that have not yet come due. Polling it is a way to know that
+// a guard is waiting on the Clock, before calling Advance.
+func (c *Clock) Waiters() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.waiters)
+}
@@ -0,0 +1,80 @@
+// Package memoryguardtest provides helpers for testing code that embeds a MemoryGuard, without spawning real
+// memory-hungry processes, or depending on procfs, so such tests are portable across platforms.
+package memoryguardtest
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	memoryguard "github.com/cognusion/go-memoryguard"
+)
+
+// Guard is a MemoryGuard over the current process, whose samples are fed by Set and Grow instead of
+// being read from procfs. DryRun is set, so a breach never actually signals the process.
+type Guard struct {
+	*memoryguard.MemoryGuard
+	sim chan int64
+}
+
+// New returns a Guard with the opts applied. Limit() (or LimitRelative()) must still be called, as usual.
+func New(opts ...memoryguard.Option) *Guard {
+	us, _ := os.FindProcess(os.Getpid())
+	g := &Guard{
+		MemoryGuard: memoryguard.New(us, opts...),
+		sim:         make(chan int64),
+	}
+	g.SimulateChan = g.sim
+	g.DryRun = true
+	return g
+}
+
+// Set reports v as the next sample, returning true once the Limit goro has received it, which also means the
+// previous sample has been fully processed. Returns false if the Limit goro has stopped (e.g. after a kill).
+func (g *Guard) Set(v int64) bool {
+	select {
+	case g.sim <- v:
+		return true
+	case <-g.Done():
+		return false
+	}
+}
+
+// Grow reports steps samples, starting at start and increasing by step each time, returning true if the
+// guard killed before all of them were received.
+func (g *Guard) Grow(start, step int64, steps int) bool {
+	return g.Feed(RampSampler(start, step), steps)
+}
+
+// Feed reports n samples from sampler, returning true if the guard killed before all of them were received.
+func (g *Guard) Feed(sampler func() int64, n int) bool {
+	for range n {
+		if !g.Set(sampler()) {
+			return true
+		}
+	}
+	return false
+}
+
+// RampSampler returns a sampler, for Feed, that returns start on its first call, and step more on each call
+// after that, so growth can be tested deterministically without any real memory. It is safe for concurrent use.
+// It is only intended for tests.
+func RampSampler(start, step int64) func() int64 {
+	var calls atomic.Int64
+	return func() int64 {
+		return start + (calls.Add(1)-1)*step
+	}
+}
+
+// WaitKilled returns true if the guard kills within timeout, or false if it doesn't.
+func (g *Guard) WaitKilled(timeout time.Duration) bool {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case <-g.KillChan:
+		return true
+	case <-t.C:
+		return false
+	}
+}
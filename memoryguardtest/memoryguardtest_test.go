@@ -0,0 +1,113 @@
+package memoryguardtest
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+
+	memoryguard "github.com/cognusion/go-memoryguard"
+)
+
+func Test_Guard(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a Guard grows past its limit", t, func() {
+		g := New(memoryguard.WithName("growing"))
+		So(g.Limit(10000), ShouldBeNil)
+		defer g.CancelWait()
+
+		So(g.Grow(1000, 1000, 50), ShouldBeTrue)
+		So(g.WaitKilled(time.Second), ShouldBeTrue)
+		So(g.Reason(), ShouldEqual, memoryguard.TriggerLimit)
+		So(g.KillEvent().Sample, ShouldBeGreaterThan, 10000)
+	})
+
+	Convey("When a Guard stays under its limit", t, func() {
+		g := New()
+		So(g.Limit(10000), ShouldBeNil)
+		defer g.CancelWait()
+
+		So(g.Grow(1000, 1000, 5), ShouldBeFalse)
+		So(g.Set(100), ShouldBeTrue)
+		So(g.Set(100), ShouldBeTrue) // the second value can't be received until the first is processed
+		So(g.WaitKilled(10*time.Millisecond), ShouldBeFalse)
+		So(g.PSS(), ShouldEqual, 100)
+	})
+}
+
+func Test_RampSampler(t *testing.T) {
+	Convey("When a RampSampler is called", t, func() {
+		ramp := RampSampler(1000, 250)
+		So(ramp(), ShouldEqual, 1000)
+		So(ramp(), ShouldEqual, 1250)
+		So(ramp(), ShouldEqual, 1500)
+
+		down := RampSampler(1000, -500)
+		So(down(), ShouldEqual, 1000)
+		So(down(), ShouldEqual, 500)
+	})
+
+	Convey("When a Guard is fed by a RampSampler past a threshold and its limit", t, func() {
+		var crossed atomic.Int64
+
+		g := New()
+		So(g.AddThreshold(0.5, func(pss, limit int64) { crossed.Store(pss) }), ShouldBeNil)
+		So(g.Limit(10000), ShouldBeNil)
+		defer g.CancelWait()
+
+		So(g.Feed(RampSampler(4000, 1000), 20), ShouldBeTrue)
+		So(crossed.Load(), ShouldEqual, 6000)
+		So(g.KillEvent().Sample, ShouldEqual, 11000)
+	})
+}
+
+func Test_Clock(t *testing.T) {
+	Convey("When a Clock is advanced", t, func() {
+		start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		c := NewClock(start)
+		soon, later := c.After(time.Minute), c.After(time.Hour)
+		So(c.Waiters(), ShouldEqual, 2)
+
+		c.Advance(2 * time.Minute)
+		So(c.Now(), ShouldEqual, start.Add(2*time.Minute))
+		So(<-soon, ShouldEqual, start.Add(2*time.Minute))
+		So(later, ShouldBeEmpty)
+		So(c.Waiters(), ShouldEqual, 1)
+
+		c.Advance(time.Hour)
+		So(later, ShouldHaveLength, 1)
+		So(c.Waiters(), ShouldEqual, 0)
+		So(c.After(0), ShouldHaveLength, 1)
+	})
+}
+
+func Test_GuardClock(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a Guard with a fake Clock is over its limit, and boosted", t, func() {
+		c := NewClock(time.Now())
+		g := New(memoryguard.WithClock(c))
+		g.OnBreach = func(*memoryguard.BreachContext) bool { return false } // never act, so we keep guarding
+		So(g.Limit(1000), ShouldBeNil)
+		defer g.CancelWait()
+
+		g.BoostLimit(1000, time.Minute)
+		So(g.Set(1500), ShouldBeTrue)
+		So(g.Set(1500), ShouldBeTrue) // the second value can't be received until the first is processed
+		So(g.TimeOverLimit(), ShouldEqual, 0)
+
+		Convey("time only passes when the Clock is advanced", func() {
+			c.Advance(2 * time.Minute)
+			So(g.EffectiveLimit(), ShouldEqual, 1000)
+			So(g.Set(1500), ShouldBeTrue)
+			So(g.Set(1500), ShouldBeTrue)
+			So(g.TimeOverLimit(), ShouldEqual, 0)
+
+			c.Advance(time.Hour)
+			So(g.TimeOverLimit(), ShouldEqual, time.Hour)
+		})
+	})
+}
@@ -0,0 +1,216 @@
+package memoryguard
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// Metric is the memory measurement a MemoryGuard samples and compares against the limit.
+// For sampling many processes at a high frequency, MetricRSSFast is by far the cheapest. There is no eBPF
+// Metric, as it would need CAP_BPF and a loader this package doesn't depend on, for little over statm.
+type Metric int
+
+const (
+	// MetricPSS sums the Pss of every mapping in /proc/[pid]/smaps. It is the most accurate,
+	// and the default.
+	MetricPSS Metric = iota
+	// MetricRSSFast reads the resident pages from /proc/[pid]/statm. It is the cheapest possible
+	// measurement, but RSS counts shared pages fully against every process mapping them, so it
+	// will overstate usage relative to PSS, sometimes greatly.
+	MetricRSSFast
+	// MetricPSSHugetlb is MetricPSS plus the explicit (hugetlbfs) hugepages mapped by the process, which are
+	// never counted in Pss: the sum of the Pss, Shared_Hugetlb, and Private_Hugetlb fields of every mapping in
+	// /proc/[pid]/smaps. Shared_Hugetlb is counted fully, not proportionally, as the kernel doesn't provide that.
+	// Transparent hugepages (AnonHugePages, ShmemPmdMapped) are already counted in Pss, so are not added again.
+	MetricPSSHugetlb
+	// MetricWeightedPSS sums the Pss of every mapping in /proc/[pid]/smaps, each multiplied by the weight in
+	// MappingWeights for its MappingType, reflecting that some memory is cheaper to reclaim than others.
+	// It parses smaps more thoroughly than MetricPSS, so is costlier.
+	MetricWeightedPSS
+	// MetricPSSShmem is MetricPSS, except that shared mappings of shared memory are counted at their Rss rather
+	// than their Pss, so shared memory is counted fully against every process mapping it, as it pressures the
+	// system no matter how many processes share it. Shared memory is a shared ("s" in the perms) mapping whose
+	// pathname is under /dev/shm/ or any other tmpfs mount of the process (from /proc/[pid]/mountinfo), is a
+	// System V segment (/SYSV...), a memfd (/memfd:...), or shared anonymous memory (/dev/zero). Everything
+	// else, including private mappings of tmpfs files, is counted at its Pss.
+	MetricPSSShmem
+	// MetricMaxRSS is the peak resident set size of this process from getrusage(RUSAGE_SELF), which doesn't need
+	// procfs. It can only guard us: getrusage(2) only counts children once they have been waited for, so it can't
+	// say anything about a running one, and Limit() returns MaxRSSSelfError for any other process. Be careful, as
+	// it differs from the others in important ways: it is a high-water mark, so it never goes down, making
+	// relative limits and Thresholds that reset meaningless; and it is RSS, so overstates shared memory as
+	// MetricRSSFast does. It is only available on Linux, and elsewhere every sample returns RusageUnavailableError.
+	MetricMaxRSS
+)
+
+// String returns the name of the Metric
+func (mt Metric) String() string {
+	switch mt {
+	case MetricPSS:
+		return "PSS"
+	case MetricRSSFast:
+		return "RSSFast"
+	case MetricPSSHugetlb:
+		return "PSSHugetlb"
+	case MetricWeightedPSS:
+		return "WeightedPSS"
+	case MetricPSSShmem:
+		return "PSSShmem"
+	case MetricMaxRSS:
+		return "MaxRSS"
+	}
+	return "Metric(" + strconv.Itoa(int(mt)) + ")"
+}
+
+// SampleInfo describes a single sample, for diagnosing its cost and accuracy.
+type SampleInfo struct {
+	// Bytes is the sampled value
+	Bytes int64
+	// Source is the file the sample was read from, or "simulated"
+	Source string
+	// MappingCount is the number of mappings summed, a proxy for the cost of the sample,
+	// or 0 if the Source isn't per-mapping (e.g. statm)
+	MappingCount int
+}
+
+// SampleInfo samples the process now with the configured Metric, returning a SampleInfo describing it,
+// or an error. It doesn't affect the Limit goro, or PSS().
+func (m *MemoryGuard) SampleInfo() (SampleInfo, error) {
+	if m.proc == nil && m.cgroup == "" {
+		return SampleInfo{}, LimitNilProcessError
+	}
+	c := m.conf()
+	return m.sampleMetricInfo(c, c.metric)
+}
+
+// sample returns the current value of the configured Metric for the process, in Bytes, or an error.
+// It also records the MappingCount, the ThreadCount if MaxThreads is set, and the Overhead if TrackOverhead is set.
+func (m *MemoryGuard) sample() (int64, error) {
+	c := m.conf()
+	if c.trackOverhead && c.simulate == nil {
+		defer m.measure()()
+	}
+	info, cheap, err := m.sampleTiered(c)
+	if err == nil && c.simulate == nil {
+		m.cheap.Store(cheap)
+		if !cheap {
+			m.mappings.Store(int64(info.MappingCount))
+		}
+		if c.maxThreads > 0 && m.cgroup == "" {
+			m.sampleThreads(c)
+		}
+	}
+	return info.Bytes, err
+}
+
+// sampleTiered returns a SampleInfo for the configured Metric, or if ConfirmWithPSS applies, for the RSS unless
+// it is within the PSSBand of the EffectiveLimit, or over it, and true if it is the RSS, or an error.
+func (m *MemoryGuard) sampleTiered(c *config) (SampleInfo, bool, error) {
+	if !c.confirmsWithPSS() || c.simulate != nil || m.cgroup != "" {
+		info, err := m.sampleMetricInfo(c, c.metric)
+		return info, false, err
+	}
+
+	info, err := m.sampleMetricInfo(c, MetricRSSFast)
+	if err != nil {
+		return SampleInfo{}, false, err
+	}
+	if l := m.EffectiveLimit(); l > 0 && float64(info.Bytes) <= float64(l)*(1-c.pssBand) {
+		// Far enough from the limit that the Metric can't be over it
+		return info, true, nil
+	}
+	info, err = m.sampleMetricInfo(c, c.metric)
+	return info, false, err
+}
+
+// confirmsWithPSS returns true if ConfirmWithPSS is set, and the Metric can never exceed the RSS, which is what
+// makes sampling the RSS instead safe.
+func (c *config) confirmsWithPSS() bool {
+	return c.confirmWithPSS && (c.metric == MetricPSS || c.metric == MetricPSSShmem)
+}
+
+// sampleMetric returns the current value of mt for the process, in Bytes, or an error.
+func (m *MemoryGuard) sampleMetric(c *config, mt Metric) (int64, error) {
+	info, err := m.sampleMetricInfo(c, mt)
+	return info.Bytes, err
+}
+
+// sampleMetricInfo returns a SampleInfo for the current value of mt for the process, or an error.
+func (m *MemoryGuard) sampleMetricInfo(c *config, mt Metric) (SampleInfo, error) {
+	if c.simulate != nil {
+		return SampleInfo{Bytes: m.lastPss.Load(), Source: "simulated"}, nil
+	}
+
+	if m.cgroup != "" {
+		return getCgroupInfo(m.cgroup)
+	} else if c.includeChildren && mt != MetricMaxRSS {
+		return m.sampleTree(c, mt)
+	}
+	return c.pidMetricInfo(mt, m.proc.Pid)
+}
+
+// pidMetricInfo returns a SampleInfo for the current value of mt for pid, or an error.
+func (c *config) pidMetricInfo(mt Metric, pid int) (SampleInfo, error) {
+	switch mt {
+	case MetricMaxRSS:
+		return getMaxRssInfo(pid)
+	case MetricRSSFast:
+		return getRssFastInfo(c.procRoot, pid)
+	case MetricPSSHugetlb:
+		return getPssHugetlbInfo(c.procRoot, pid)
+	case MetricWeightedPSS:
+		return getWeightedPssInfo(c.procRoot, pid, c.mappingWeights)
+	case MetricPSSShmem:
+		return getShmemPssInfo(c.procRoot, pid)
+	default:
+		return getPssInfo(c.procRoot, pid)
+	}
+}
+
+// getRssFast takes a procfs root and a pid, and returns the resident set size in Bytes
+// from statm, or an error
+func getRssFast(root string, pid int) (int64, error) {
+	info, err := getRssFastInfo(root, pid)
+	return info.Bytes, err
+}
+
+// getRssFastInfo is getRssFast, returning a SampleInfo.
+func getRssFastInfo(root string, pid int) (SampleInfo, error) {
+	path := procPath(root, pid, "statm")
+	statm, err := os.ReadFile(path)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+
+	fields := bytes.Fields(statm)
+	if len(fields) < 2 {
+		return SampleInfo{}, StatmFormatError
+	}
+	pages, err := strconv.ParseInt(string(fields[1]), 10, 64)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+
+	return SampleInfo{Bytes: pages * int64(os.Getpagesize()), Source: path}, nil
+}
+
+// getPssHugetlb takes a procfs root and a pid, and returns the sum of PSS and hugetlb page sizes in Bytes
+// from smaps, or an error
+func getPssHugetlb(root string, pid int) (int64, error) {
+	info, err := getPssHugetlbInfo(root, pid)
+	return info.Bytes, err
+}
+
+// getPssHugetlbInfo is getPssHugetlb, returning a SampleInfo.
+func getPssHugetlbInfo(root string, pid int) (SampleInfo, error) {
+	totals, err := getSmapsTotals(root, pid)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	return SampleInfo{
+		Bytes:        totals.Pss + totals.SharedHugetlb + totals.PrivateHugetlb,
+		Source:       procPath(root, pid, "smaps"),
+		MappingCount: totals.Mappings,
+	}, nil
+}
@@ -0,0 +1,263 @@
+package memoryguard
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_GetRssFast(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard checks statm with a valid pid for RSS", t, func() {
+		rss, e := getRssFast(DefaultProcRoot, os.Getpid())
+		Convey("it doesn't return an error, and RSS is in the neighborhood of PSS", func() {
+			So(e, ShouldBeNil)
+			pss, _ := getPss(DefaultProcRoot, os.Getpid())
+			So(rss, ShouldBeGreaterThan, pss/2) // Sampled at different times, so be lenient
+		})
+	})
+
+	Convey("When a MemoryGuard checks a bogus statm", t, func() {
+		root := t.TempDir()
+		os.Mkdir(filepath.Join(root, "1"), 0755)
+		os.WriteFile(filepath.Join(root, "1", "statm"), []byte("1234\n"), 0644)
+		_, e := getRssFast(root, 1)
+		Convey("it returns an error", func() {
+			So(e, ShouldEqual, StatmFormatError)
+		})
+	})
+}
+
+func Test_MemoryGuardMetricRSSFast(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard using MetricRSSFast is asked for PSS", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.Metric = MetricRSSFast
+
+		Convey("it returns the RSS instead", func() {
+			So(mg.PSS(), ShouldBeGreaterThan, 0)
+			So(mg.Metric.String(), ShouldEqual, "RSSFast")
+			So(Metric(99).String(), ShouldEqual, "Metric(99)")
+		})
+	})
+}
+
+func Test_GetPssHugetlb(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard checks smaps with hugetlb mappings", t, func() {
+		root := t.TempDir()
+		os.Mkdir(filepath.Join(root, "1"), 0755)
+		os.WriteFile(filepath.Join(root, "1", "smaps"), []byte(`7f0000000000-7f0040000000 rw-s 00000000 00:0f 1234 /dev/hugepages/db
+Rss:                   0 kB
+Pss:                   0 kB
+AnonHugePages:         0 kB
+Shared_Hugetlb:     4096 kB
+Private_Hugetlb:    2048 kB
+7f0040000000-7f0040200000 rw-p 00000000 00:00 0
+Rss:                2048 kB
+Pss:                2048 kB
+AnonHugePages:      2048 kB
+Shared_Hugetlb:        0 kB
+Private_Hugetlb:       0 kB
+`), 0644)
+
+		Convey("the hugetlb pages are added to the Pss, but transparent hugepages aren't counted twice", func() {
+			pss, err := getPss(root, 1)
+			So(err, ShouldBeNil)
+			So(pss, ShouldEqual, 2048*1024)
+
+			xss, err := getPssHugetlb(root, 1)
+			So(err, ShouldBeNil)
+			So(xss, ShouldEqual, (2048+4096+2048)*1024)
+			So(MetricPSSHugetlb.String(), ShouldEqual, "PSSHugetlb")
+		})
+	})
+}
+
+func Test_MemoryGuardMetricMaxRSS(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard using MetricMaxRSS guards us without procfs", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithMetric(MetricMaxRSS), WithProcRoot(t.TempDir()), WithOnDemand())
+		So(mg.Limit(1024), ShouldBeNil) // 1KB
+		defer mg.Cancel()
+
+		Convey("it samples our high-water mark from getrusage", func() {
+			info, err := mg.SampleInfo()
+			So(err, ShouldBeNil)
+			So(info.Bytes, ShouldBeGreaterThan, 0)
+			So(info.Source, ShouldEqual, "getrusage(RUSAGE_SELF)")
+			So(mg.Metric.String(), ShouldEqual, "MaxRSS")
+		})
+	})
+
+	Convey("When a MemoryGuard using MetricMaxRSS guards another process, it refuses", t, func() {
+		cmd := exec.Command("sleep", "10")
+		So(cmd.Start(), ShouldBeNil)
+		defer cmd.Wait()
+		defer cmd.Process.Kill()
+
+		mg := New(cmd.Process, WithMetric(MetricMaxRSS))
+		So(mg.Limit(1024), ShouldEqual, MaxRSSSelfError)
+		_, err := mg.SampleInfo()
+		So(err, ShouldEqual, MaxRSSSelfError)
+	})
+}
+
+func Test_MemoryGuardSampleInfo(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is asked for SampleInfo with each Metric", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+
+		info, err := New(us).SampleInfo()
+		So(err, ShouldBeNil)
+		So(info.Bytes, ShouldBeGreaterThan, 0)
+		So(info.Source, ShouldEqual, procPath(DefaultProcRoot, os.Getpid(), "smaps"))
+		So(info.MappingCount, ShouldBeGreaterThan, 1)
+
+		info, err = New(us, WithMetric(MetricRSSFast)).SampleInfo()
+		So(err, ShouldBeNil)
+		So(info.Bytes, ShouldBeGreaterThan, 0)
+		So(info.Source, ShouldEqual, procPath(DefaultProcRoot, os.Getpid(), "statm"))
+		So(info.MappingCount, ShouldEqual, 0)
+
+		info, err = New(us, WithMetric(MetricPSSHugetlb)).SampleInfo()
+		So(err, ShouldBeNil)
+		So(info.MappingCount, ShouldBeGreaterThan, 1)
+
+		_, err = New(nil).SampleInfo()
+		So(err, ShouldEqual, LimitNilProcessError)
+	})
+}
+
+func Test_MemoryGuardConfirmWithPSS(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard with ConfirmWithPSS is running on a fake procfs", t, func() {
+		root := t.TempDir()
+		dir := filepath.Join(root, strconv.Itoa(os.Getpid()))
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(root, "stat"), []byte("cpu 0 0 0 0\n"), 0644)
+		os.WriteFile(filepath.Join(dir, "smaps"), []byte("00400000-00401000 r-xp 00000000 fd:01 1 /bin/thing\nRss: 8 kB\nPss: 4 kB\n"), 0644)
+		statm := func(pages int) {
+			os.WriteFile(filepath.Join(dir, "statm"), []byte("1000 "+strconv.Itoa(pages)+" 0 0 0 0 0\n"), 0644)
+		}
+		page := int64(os.Getpagesize())
+
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		clock := &stepClock{}
+		clock.now.Store(time.Now().UnixNano())
+		mg := New(us, WithTickSource(tick), WithClock(clock), WithConfirmWithPSS(0.5), WithGrowth(time.Minute, 0.5))
+		mg.ProcRoot = root
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(100*page), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("far from the limit, it samples the RSS", func() {
+			statm(10)
+			tick <- time.Now()
+			tick <- time.Now() // the second tick can't be received until the first is processed
+			So(mg.PSS(), ShouldEqual, 10*page)
+			So(mg.MappingCount(), ShouldEqual, 0)
+		})
+
+		Convey("within the band, or over the limit, it samples the PSS, and only that decides", func() {
+			statm(60)
+			tick <- time.Now()
+			tick <- time.Now() // the second tick can't be received until the first is processed
+			So(mg.PSS(), ShouldEqual, 4096)
+			So(mg.MappingCount(), ShouldEqual, 1)
+
+			statm(200)
+			tick <- time.Now()
+			tick <- time.Now()
+			So(mg.PSS(), ShouldEqual, 4096)
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+
+			Convey("and dropping back to the RSS isn't mistaken for growth, or a change in mappings", func() {
+				clock.step(2 * time.Minute)
+				statm(49)
+				tick <- time.Now()
+				tick <- time.Now()
+				So(mg.PSS(), ShouldEqual, 49*page)
+				So(mg.MappingCount(), ShouldEqual, 1)
+				So(mg.Reason(), ShouldEqual, TriggerNone)
+				So(mg.RollingBaseline(), ShouldEqual, 0) // the PSS aged out, and the RSS never went in
+			})
+		})
+	})
+
+	Convey("When a MemoryGuard with ConfirmWithPSS and an OutlierFactor is running on a fake procfs", t, func() {
+		root := t.TempDir()
+		dir := filepath.Join(root, strconv.Itoa(os.Getpid()))
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(root, "stat"), []byte("cpu 0 0 0 0\n"), 0644)
+		page := int64(os.Getpagesize())
+		pss := 20 * page
+		os.WriteFile(filepath.Join(dir, "smaps"), []byte("00400000-00401000 r-xp 00000000 fd:01 1 /bin/thing\nRss: "+strconv.FormatInt(pss/1024, 10)+" kB\nPss: "+strconv.FormatInt(pss/1024, 10)+" kB\n"), 0644)
+		statm := func(pages int) {
+			os.WriteFile(filepath.Join(dir, "statm"), []byte("1000 "+strconv.Itoa(pages)+" 0 0 0 0 0\n"), 0644)
+		}
+
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us, WithTickSource(tick), WithConfirmWithPSS(0.5), WithOutlierFactor(2))
+		mg.ProcRoot = root
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(100*page), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("the RSS samples don't set the median the PSS is judged against", func() {
+			statm(60) // within the band, so the PSS
+			for range 3 {
+				tick <- time.Now()
+			}
+			statm(45) // far from the limit, so the RSS, more than twice the PSS
+			for range 5 {
+				tick <- time.Now()
+			}
+			statm(51) // within the band again
+			tick <- time.Now()
+			tick <- time.Now() // the second tick can't be received until the first is processed
+			So(mg.PSS(), ShouldEqual, pss)
+			So(mg.OutliersRejected(), ShouldEqual, 0)
+		})
+	})
+
+	Convey("When a MemoryGuard with ConfirmWithPSS is running on a fake procfs with MetricPSSHugetlb", t, func() {
+		root := t.TempDir()
+		dir := filepath.Join(root, strconv.Itoa(os.Getpid()))
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(root, "stat"), []byte("cpu 0 0 0 0\n"), 0644)
+		os.WriteFile(filepath.Join(dir, "smaps"), []byte("00400000-00401000 rw-s 00000000 00:0f 1 /dev/hugepages/x\nRss: 0 kB\nPss: 0 kB\nShared_Hugetlb: 2048 kB\n"), 0644)
+		os.WriteFile(filepath.Join(dir, "statm"), []byte("1000 1 0 0 0 0 0\n"), 0644)
+
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us, WithTickSource(tick), WithConfirmWithPSS(0.5), WithMetric(MetricPSSHugetlb))
+		mg.ProcRoot = root
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1024*1024), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("it is ignored, as the hugepages aren't in the RSS, so it still kills", func() {
+			tick <- time.Now()
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerLimit)
+			So(mg.PSS(), ShouldEqual, 2048*1024)
+		})
+	})
+}
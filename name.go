@@ -0,0 +1,62 @@
+package memoryguard
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// NameFrom is where a MemoryGuard gets its name from, if Name is unset.
+type NameFrom int
+
+const (
+	// NameFromPID uses the PID of the process.
+	NameFromPID NameFrom = iota
+	// NameFromComm uses the command name of the process, from /proc/[pid]/comm, e.g. "postgres".
+	NameFromComm
+	// NameFromCmdline uses the full command line of the process, from /proc/[pid]/cmdline, with arguments
+	// separated by spaces.
+	NameFromCmdline
+)
+
+// String returns the name of the NameFrom
+func (nf NameFrom) String() string {
+	switch nf {
+	case NameFromPID:
+		return "PID"
+	case NameFromComm:
+		return "Comm"
+	case NameFromCmdline:
+		return "Cmdline"
+	}
+	return "NameFrom(" + strconv.Itoa(int(nf)) + ")"
+}
+
+// deriveName takes a procfs root, a pid, and a NameFrom, and returns the name. If the name
+// can't be read, or is empty (e.g. a kernel thread has no cmdline), the PID is used instead.
+func deriveName(root string, pid int, from NameFrom) string {
+	var file string
+	switch from {
+	case NameFromComm:
+		file = "comm"
+	case NameFromCmdline:
+		file = "cmdline"
+	default:
+		return strconv.Itoa(pid)
+	}
+
+	if name := readProcString(root, pid, file); name != "" {
+		return name
+	}
+	return strconv.Itoa(pid)
+}
+
+// readProcString takes a procfs root, a pid, and a file (e.g. "cmdline"), and returns its contents with any
+// NUL separators as spaces, and trimmed, or "" if it can't be read.
+func readProcString(root string, pid int, file string) string {
+	b, err := os.ReadFile(procPath(root, pid, file))
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(bytes.ReplaceAll(bytes.TrimRight(b, "\x00"), []byte{0}, []byte(" "))))
+}
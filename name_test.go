@@ -0,0 +1,44 @@
+package memoryguard
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_DeriveName(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When names are derived from a procfs", t, func() {
+		root := t.TempDir()
+		os.Mkdir(filepath.Join(root, "1"), 0755)
+		os.WriteFile(filepath.Join(root, "1", "comm"), []byte("postgres\n"), 0644)
+		os.WriteFile(filepath.Join(root, "1", "cmdline"), []byte("postgres\x00-D\x00/var/lib/pg\x00"), 0644)
+		os.Mkdir(filepath.Join(root, "2"), 0755)
+		os.WriteFile(filepath.Join(root, "2", "cmdline"), []byte(""), 0644)
+
+		So(deriveName(root, 1, NameFromPID), ShouldEqual, "1")
+		So(deriveName(root, 1, NameFromComm), ShouldEqual, "postgres")
+		So(deriveName(root, 1, NameFromCmdline), ShouldEqual, "postgres -D /var/lib/pg")
+		So(deriveName(root, 2, NameFromCmdline), ShouldEqual, "2") // empty
+		So(deriveName(root, 2, NameFromComm), ShouldEqual, "2")    // missing
+		So(NameFromComm.String(), ShouldEqual, "Comm")
+		So(NameFrom(9).String(), ShouldEqual, "NameFrom(9)")
+
+		So(readProcString(root, 1, "cmdline"), ShouldEqual, "postgres -D /var/lib/pg")
+		So(readProcString(root, 2, "cmdline"), ShouldEqual, "")
+		So(readProcString(root, 3, "cmdline"), ShouldEqual, "") // missing
+	})
+
+	Convey("When a MemoryGuard derives its name from comm", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithNameFrom(NameFromComm))
+		comm, _ := os.ReadFile(procPath(DefaultProcRoot, os.Getpid(), "comm"))
+		So(mg.name(), ShouldNotEqual, strconv.Itoa(os.Getpid()))
+		So(mg.name()+"\n", ShouldEqual, string(comm))
+	})
+}
@@ -0,0 +1,94 @@
+package memoryguard
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// NamespacePids takes a procfs root and a pid as seen in that procfs, and returns the pid of the process
+// in each of the PID namespaces it is a member of, as the NSpid field of its status orders them: outermost
+// (that of the procfs, usually the host) first, and innermost last. A process not in a nested namespace
+// will just have its own pid.
+func NamespacePids(root string, pid int) ([]int, error) {
+	f, err := os.Open(procPath(root, pid, "status"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pfx := []byte("NSpid:")
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Bytes()
+		if !bytes.HasPrefix(line, pfx) {
+			continue
+		}
+		var pids []int
+		for _, field := range bytes.Fields(line[len(pfx):]) {
+			p, err := strconv.Atoi(string(field))
+			if err != nil {
+				return nil, err
+			}
+			pids = append(pids, p)
+		}
+		return pids, nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, NSpidUnavailableError
+}
+
+// HostPid takes a procfs root, the pid (as seen in that procfs) of any process in the target PID namespace,
+// such as a container's init, and a pid as seen inside that namespace, and returns the pid of the same process
+// as seen in the procfs. Returns NSpidNotFoundError if there is no such process. Reading the namespace of
+// another process requires the same access as reading its smaps: running as the same user, or having
+// CAP_SYS_PTRACE.
+func HostPid(root string, nsRef, nsPid int) (int, error) {
+	ns, err := os.Readlink(procPath(root, nsRef, "ns/pid"))
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		pids, err := NamespacePids(root, pid)
+		if err != nil || len(pids) == 0 || pids[len(pids)-1] != nsPid {
+			continue
+		}
+		if pns, err := os.Readlink(procPath(root, pid, "ns/pid")); err == nil && pns == ns {
+			return pid, nil
+		}
+	}
+
+	return 0, NSpidNotFoundError
+}
+
+// NewInNamespace takes a procfs root, the pid (as seen in that procfs) of any process in the target PID namespace,
+// a pid as seen inside that namespace, and optional Options, and returns a MemoryGuard for the process, with the
+// ProcRoot set, or an error if the process can't be found. Finding it requires the access HostPid does, and
+// killing it additionally requires running as the same user, or having CAP_KILL.
+func NewInNamespace(root string, nsRef, nsPid int, opts ...Option) (*MemoryGuard, error) {
+	pid, err := HostPid(root, nsRef, nsPid)
+	if err != nil {
+		return nil, err
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(proc, append([]Option{WithProcRoot(root)}, opts...)...), nil
+}
@@ -0,0 +1,48 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_NamespacePids(t *testing.T) {
+	Convey("When our namespace pids are read", t, func() {
+		pids, err := NamespacePids(DefaultProcRoot, os.Getpid())
+
+		Convey("the first is our pid as seen by the procfs", func() {
+			So(err, ShouldBeNil)
+			So(pids, ShouldNotBeEmpty)
+			So(pids[0], ShouldEqual, os.Getpid())
+		})
+	})
+}
+
+func Test_HostPid(t *testing.T) {
+	Convey("When we look ourselves up by our innermost pid, in our own namespace", t, func() {
+		pids, _ := NamespacePids(DefaultProcRoot, os.Getpid())
+		pid, err := HostPid(DefaultProcRoot, os.Getpid(), pids[len(pids)-1])
+
+		Convey("we find ourselves", func() {
+			So(err, ShouldBeNil)
+			So(pid, ShouldEqual, os.Getpid())
+		})
+
+		Convey("and NewInNamespace guards us", func() {
+			mg, err := NewInNamespace(DefaultProcRoot, os.Getpid(), pids[len(pids)-1], WithName("nsbob"))
+			So(err, ShouldBeNil)
+			So(mg.proc.Pid, ShouldEqual, os.Getpid())
+			So(mg.Name, ShouldEqual, "nsbob")
+			So(mg.ProcRoot, ShouldEqual, DefaultProcRoot)
+		})
+	})
+
+	Convey("When we look up a pid that doesn't exist", t, func() {
+		_, err := HostPid(DefaultProcRoot, os.Getpid(), -10)
+
+		Convey("it returns an error", func() {
+			So(err, ShouldEqual, NSpidNotFoundError)
+		})
+	})
+}
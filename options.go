@@ -0,0 +1,402 @@
+package memoryguard
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Option is a function that configures a MemoryGuard, for use with New.
+type Option func(*MemoryGuard)
+
+// applied returns a MemoryGuard with only opts applied, without New's channels and defaults, for reading
+// what they configure (e.g. ProcRoot, Clock) for the MemoryGuards to come. It must not guard anything.
+func applied(opts []Option) *MemoryGuard {
+	var m MemoryGuard
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return &m
+}
+
+// WithName sets the Name.
+func WithName(name string) Option {
+	return func(m *MemoryGuard) {
+		m.Name = name
+	}
+}
+
+// WithNameFrom sets NameFrom.
+func WithNameFrom(from NameFrom) Option {
+	return func(m *MemoryGuard) {
+		m.NameFrom = from
+	}
+}
+
+// WithShouldKill sets the ShouldKill.
+func WithShouldKill(f func(sample, limit int64, history []Sample) bool) Option {
+	return func(m *MemoryGuard) {
+		m.ShouldKill = f
+	}
+}
+
+// WithMaxMappings sets the MaxMappings.
+func WithMaxMappings(n int) Option {
+	return func(m *MemoryGuard) {
+		m.MaxMappings = n
+	}
+}
+
+// WithMaxThreads sets the MaxThreads, and the OnThreads, which may be nil.
+func WithMaxThreads(n int, f func(threads, max int)) Option {
+	return func(m *MemoryGuard) {
+		m.MaxThreads = n
+		m.OnThreads = f
+	}
+}
+
+// WithIncludeChildren sets IncludeChildren.
+func WithIncludeChildren() Option {
+	return func(m *MemoryGuard) {
+		m.IncludeChildren = true
+	}
+}
+
+// WithConfirmWithPSS sets ConfirmWithPSS, and the PSSBand.
+func WithConfirmWithPSS(band float64) Option {
+	return func(m *MemoryGuard) {
+		m.ConfirmWithPSS = true
+		m.PSSBand = band
+	}
+}
+
+// WithSkipLimitCheck sets SkipLimitCheck.
+func WithSkipLimitCheck() Option {
+	return func(m *MemoryGuard) {
+		m.SkipLimitCheck = true
+	}
+}
+
+// WithLowerLimitGrace sets the LowerLimitGrace, or if grace is zero or negative, sets ImmediateEnforce.
+func WithLowerLimitGrace(grace time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.LowerLimitGrace = grace
+		m.ImmediateEnforce = grace <= 0
+	}
+}
+
+// WithLimitSchedule sets the LimitSchedule.
+func WithLimitSchedule(windows ...ScheduleWindow) Option {
+	return func(m *MemoryGuard) {
+		m.LimitSchedule = windows
+	}
+}
+
+// WithLabels sets the Labels.
+func WithLabels(labels map[string]string) Option {
+	return func(m *MemoryGuard) {
+		m.Labels = labels
+	}
+}
+
+// WithDrain sets the OnDrain and DrainFraction, and the OnShutdown and ShutdownGrace. Either function may be nil.
+func WithDrain(onDrain func(), fraction float64, onShutdown func(), grace time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.OnDrain = onDrain
+		m.DrainFraction = fraction
+		m.OnShutdown = onShutdown
+		m.ShutdownGrace = grace
+	}
+}
+
+// WithShutdownFunc sets the ShutdownFunc.
+func WithShutdownFunc(f func()) Option {
+	return func(m *MemoryGuard) {
+		m.ShutdownFunc = f
+	}
+}
+
+// WithInterval sets the Interval.
+func WithInterval(interval time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.Interval = interval
+	}
+}
+
+// WithAdaptInterval sets AdaptInterval to true.
+func WithAdaptInterval() Option {
+	return func(m *MemoryGuard) {
+		m.AdaptInterval = true
+	}
+}
+
+// WithIntervalJitter sets IntervalJitter.
+func WithIntervalJitter(jitter time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.IntervalJitter = jitter
+	}
+}
+
+// WithLogger sets the DebugOut and ErrOut loggers. Either may be nil to leave it as-is.
+func WithLogger(debugOut, errOut *log.Logger) Option {
+	return func(m *MemoryGuard) {
+		if debugOut != nil {
+			m.DebugOut = debugOut
+		}
+		if errOut != nil {
+			m.ErrOut = errOut
+		}
+	}
+}
+
+// WithLogFunc sets the LogFunc.
+func WithLogFunc(f LogFunc) Option {
+	return func(m *MemoryGuard) {
+		m.LogFunc = f
+	}
+}
+
+// WithStatsFrequency sets the StatsFrequency.
+func WithStatsFrequency(frequency time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.StatsFrequency = frequency
+	}
+}
+
+// WithKillSignal sets the KillSignal, and the KillConfirmTimeout after which it will be escalated.
+func WithKillSignal(sig os.Signal, confirmTimeout time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.KillSignal = sig
+		m.KillConfirmTimeout = confirmTimeout
+	}
+}
+
+// WithPreKillSignal sets PreKillSignal and PreKillDelay.
+func WithPreKillSignal(sig os.Signal, delay time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.PreKillSignal = sig
+		m.PreKillDelay = delay
+	}
+}
+
+// WithKillGroup sets KillGroup to true.
+func WithKillGroup() Option {
+	return func(m *MemoryGuard) {
+		m.KillGroup = true
+	}
+}
+
+// WithMinAvailable sets the MinAvailable.
+func WithMinAvailable(floor int64) Option {
+	return func(m *MemoryGuard) {
+		m.MinAvailable = floor
+	}
+}
+
+// WithMinKillInterval sets the MinKillInterval.
+func WithMinKillInterval(interval time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.MinKillInterval = interval
+	}
+}
+
+// WithPidfd sets UsePidfd to true.
+func WithPidfd() Option {
+	return func(m *MemoryGuard) {
+		m.UsePidfd = true
+	}
+}
+
+// WithDryRun sets DryRun to true.
+func WithDryRun() Option {
+	return func(m *MemoryGuard) {
+		m.DryRun = true
+	}
+}
+
+// WithDeadlineKill sets DeadlineKill to true.
+func WithDeadlineKill() Option {
+	return func(m *MemoryGuard) {
+		m.DeadlineKill = true
+	}
+}
+
+// WithMetric sets the Metric.
+func WithMetric(metric Metric) Option {
+	return func(m *MemoryGuard) {
+		m.Metric = metric
+	}
+}
+
+// WithWarnFraction sets WarnFraction.
+func WithWarnFraction(fraction float64) Option {
+	return func(m *MemoryGuard) {
+		m.WarnFraction = fraction
+	}
+}
+
+// WithQuiesceGC sets QuiesceGC to true.
+func WithQuiesceGC() Option {
+	return func(m *MemoryGuard) {
+		m.QuiesceGC = true
+	}
+}
+
+// WithAvgWindow sets AvgWindow.
+func WithAvgWindow(span time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.AvgWindow = span
+	}
+}
+
+// WithCompositeMetrics sets CompositeMode and CompositeMetrics.
+func WithCompositeMetrics(mode CompositeMode, metrics ...Metric) Option {
+	return func(m *MemoryGuard) {
+		m.CompositeMode = mode
+		m.CompositeMetrics = metrics
+	}
+}
+
+// WithMappingWeights sets Metric to MetricWeightedPSS, and MappingWeights.
+func WithMappingWeights(weights map[MappingType]float64) Option {
+	return func(m *MemoryGuard) {
+		m.Metric = MetricWeightedPSS
+		m.MappingWeights = weights
+	}
+}
+
+// WithPSI sets the PSIThreshold, PSIField, and PSICombined.
+func WithPSI(threshold float64, field PSIField, combined bool) Option {
+	return func(m *MemoryGuard) {
+		m.PSIThreshold = threshold
+		m.PSIField = field
+		m.PSICombined = combined
+	}
+}
+
+// WithProcRoot sets the ProcRoot.
+func WithProcRoot(root string) Option {
+	return func(m *MemoryGuard) {
+		m.ProcRoot = root
+	}
+}
+
+// WithTrackOverhead sets TrackOverhead.
+func WithTrackOverhead() Option {
+	return func(m *MemoryGuard) {
+		m.TrackOverhead = true
+	}
+}
+
+// WithSampleTimeout sets the SampleTimeout.
+func WithSampleTimeout(timeout time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.SampleTimeout = timeout
+	}
+}
+
+// WithTickSource sets the TickSource.
+func WithTickSource(tick <-chan time.Time) Option {
+	return func(m *MemoryGuard) {
+		m.TickSource = tick
+	}
+}
+
+// WithGrowth sets GrowthWindow and GrowthFraction.
+func WithGrowth(window time.Duration, fraction float64) Option {
+	return func(m *MemoryGuard) {
+		m.GrowthWindow = window
+		m.GrowthFraction = fraction
+	}
+}
+
+// WithSystemdWatchdog sets SystemdWatchdog to true.
+func WithSystemdWatchdog() Option {
+	return func(m *MemoryGuard) {
+		m.SystemdWatchdog = true
+	}
+}
+
+// WithMinProcessAge sets MinProcessAge.
+func WithMinProcessAge(age time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.MinProcessAge = age
+	}
+}
+
+// WithStaleAfter sets StaleAfter.
+func WithStaleAfter(after time.Duration) Option {
+	return func(m *MemoryGuard) {
+		m.StaleAfter = after
+	}
+}
+
+// WithOutlierFactor sets OutlierFactor.
+func WithOutlierFactor(factor float64) Option {
+	return func(m *MemoryGuard) {
+		m.OutlierFactor = factor
+	}
+}
+
+// WithCloseKillChanOnStop sets CloseKillChanOnStop to true.
+func WithCloseKillChanOnStop() Option {
+	return func(m *MemoryGuard) {
+		m.CloseKillChanOnStop = true
+	}
+}
+
+// WithClock sets the Clock.
+func WithClock(clock Clock) Option {
+	return func(m *MemoryGuard) {
+		m.Clock = clock
+	}
+}
+
+// WithOnStats sets the OnStats.
+func WithOnStats(f func(GuardStats)) Option {
+	return func(m *MemoryGuard) {
+		m.OnStats = f
+	}
+}
+
+// WithOnSample sets the OnSample.
+func WithOnSample(f func(Sample)) Option {
+	return func(m *MemoryGuard) {
+		m.OnSample = f
+	}
+}
+
+// WithOnBreach sets OnBreach.
+func WithOnBreach(f func(*BreachContext) bool) Option {
+	return func(m *MemoryGuard) {
+		m.OnBreach = f
+	}
+}
+
+// WithOnRecover sets OnRecover.
+func WithOnRecover(f func(afterErrors int)) Option {
+	return func(m *MemoryGuard) {
+		m.OnRecover = f
+	}
+}
+
+// WithOnExit sets OnExit.
+func WithOnExit(f func(pid int)) Option {
+	return func(m *MemoryGuard) {
+		m.OnExit = f
+	}
+}
+
+// WithOnDemand sets OnDemand to true.
+func WithOnDemand() Option {
+	return func(m *MemoryGuard) {
+		m.OnDemand = true
+	}
+}
+
+// WithProfileLabels sets ProfileLabels to true.
+func WithProfileLabels() Option {
+	return func(m *MemoryGuard) {
+		m.ProfileLabels = true
+	}
+}
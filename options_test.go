@@ -0,0 +1,54 @@
+package memoryguard
+
+import (
+	"log"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_NewWithOptions(t *testing.T) {
+	Convey("When a MemoryGuard is created with Options", t, func() {
+		var (
+			us, _ = os.FindProcess(os.Getpid())
+			dl    = log.New(os.Stdout, "debug ", 0)
+			tick  = make(chan time.Time)
+		)
+		mg := New(us,
+			WithName("bob"),
+			WithInterval(time.Hour),
+			WithLogger(dl, nil),
+			WithLogFunc(func(string, string, ...any) {}),
+			WithStatsFrequency(time.Second),
+			WithKillSignal(syscall.SIGTERM, time.Second),
+			WithMinKillInterval(time.Minute),
+			WithMetric(MetricRSSFast),
+			WithProcRoot("/elsewhere"),
+			WithSampleTimeout(time.Second),
+			WithTickSource(tick),
+			WithOnDemand(),
+			WithProfileLabels(),
+		)
+
+		Convey("the fields are all set", func() {
+			So(mg.Name, ShouldEqual, "bob")
+			So(mg.Interval, ShouldEqual, time.Hour)
+			So(mg.DebugOut, ShouldEqual, dl)
+			So(mg.ErrOut, ShouldNotBeNil)
+			So(mg.LogFunc, ShouldNotBeNil)
+			So(mg.StatsFrequency, ShouldEqual, time.Second)
+			So(mg.KillSignal, ShouldEqual, syscall.SIGTERM)
+			So(mg.KillConfirmTimeout, ShouldEqual, time.Second)
+			So(mg.MinKillInterval, ShouldEqual, time.Minute)
+			So(mg.Metric, ShouldEqual, MetricRSSFast)
+			So(mg.ProcRoot, ShouldEqual, "/elsewhere")
+			So(mg.SampleTimeout, ShouldEqual, time.Second)
+			So(mg.TickSource, ShouldEqual, (<-chan time.Time)(tick))
+			So(mg.OnDemand, ShouldBeTrue)
+			So(mg.ProfileLabels, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package memoryguard
+
+import (
+	"slices"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// minOutlierSamples is the number of prior samples needed before OutlierFactor is applied
+const minOutlierSamples = 3
+
+// OutliersRejected returns the number of samples skipped by OutlierFactor.
+func (m *MemoryGuard) OutliersRejected() int64 {
+	return m.outliers.Load()
+}
+
+// outlier returns true, and logs, if OutlierFactor is set and xss differs from the median of the samples
+// before it by more than that factor. Only the accurate samples count, not ConfirmWithPSS's RSS, which would
+// skew the median. Assumes xss is the latest accurate sample.
+func (m *MemoryGuard) outlier(name string, xss int64) bool {
+	factor := m.conf().outlierFactor
+	if factor <= 0 {
+		return false
+	}
+
+	hist := m.accurate.samples()
+	if len(hist) > 0 {
+		hist = hist[:len(hist)-1] // that's xss
+	}
+	if len(hist) < minOutlierSamples {
+		return false
+	}
+
+	med := float64(median(hist))
+	if float64(xss) <= med*factor && float64(xss) >= med/factor {
+		return false
+	}
+	m.outliers.Add(1)
+	m.logf(LevelError, []any{"name", name, "pss", xss, "median", int64(med), "factor", factor}, "[%s] MemoryGuard: %s is more than %.1fx off the median of %s, ignoring\n", name, humanity.ByteFormat(xss), factor, humanity.ByteFormat(int64(med)))
+	return true
+}
+
+// median returns the median value of the samples, which must not be empty.
+func median(samples []Sample) int64 {
+	values := make([]int64, len(samples))
+	for i := range samples {
+		values[i] = samples[i].Value
+	}
+	slices.Sort(values)
+	if n := len(values); n%2 == 0 {
+		return (values[n/2-1] + values[n/2]) / 2
+	}
+	return values[len(values)/2]
+}
@@ -0,0 +1,49 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Median(t *testing.T) {
+	Convey("When the median of samples is taken", t, func() {
+		So(median([]Sample{{Value: 3}, {Value: 1}, {Value: 2}}), ShouldEqual, 2)
+		So(median([]Sample{{Value: 4}, {Value: 1}, {Value: 2}, {Value: 3}}), ShouldEqual, 2) // (2+3)/2
+		So(median([]Sample{{Value: 7}}), ShouldEqual, 7)
+	})
+}
+
+func Test_MemoryGuardOutlierFactor(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard with an OutlierFactor sees a momentary absurd value", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithOutlierFactor(4))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(5000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		mg.SimulateChan <- 1000
+		mg.SimulateChan <- 1000
+		mg.SimulateChan <- 1000
+		mg.SimulateChan <- 50000
+		mg.SimulateChan <- 1000 // the fifth value can't be received until the fourth is processed
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+		So(mg.OutliersRejected(), ShouldEqual, 1)
+		So(mg.peak.Load(), ShouldEqual, 50000) // but it's still recorded
+
+		Convey("but a sustained jump is acted on once it is the median", func() {
+			mg.SimulateChan <- 6000
+			mg.SimulateChan <- 6000
+			mg.SimulateChan <- 6000
+			mg.SimulateChan <- 6000
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerLimit)
+			So(mg.OutliersRejected(), ShouldEqual, 4)
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package memoryguard
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Overhead is the cumulative cost of sampling, from TrackOverhead.
+type Overhead struct {
+	// CPUTime is the user and system CPU time spent sampling
+	CPUTime time.Duration
+	// BytesRead is the number of bytes read (e.g. from procfs) while sampling
+	BytesRead int64
+	// Samples is the number of samples measured
+	Samples int64
+}
+
+// overhead is the goro-safe accumulator behind Overhead
+type overhead struct {
+	cpu     atomic.Int64
+	bytes   atomic.Int64
+	samples atomic.Int64
+}
+
+// Overhead returns the cumulative cost of sampling, if TrackOverhead is set, or the zero Overhead.
+// See SampleLatency for the wall time.
+func (m *MemoryGuard) Overhead() Overhead {
+	return Overhead{
+		CPUTime:   time.Duration(m.overhead.cpu.Load()),
+		BytesRead: m.overhead.bytes.Load(),
+		Samples:   m.overhead.samples.Load(),
+	}
+}
+
+// measure returns a func to call when the sampling that follows is done, which adds its cost to the Overhead.
+// The goro is locked to its thread until then, so the thread's usage is all ours. If the thread's usage can't
+// be read (e.g. not Linux), the func does nothing.
+func (m *MemoryGuard) measure() func() {
+	runtime.LockOSThread()
+	cpu, bytes, err := threadUsage()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return func() {}
+	}
+	return func() {
+		defer runtime.UnlockOSThread()
+		if cpu2, bytes2, err := threadUsage(); err == nil {
+			m.overhead.cpu.Add(int64(cpu2 - cpu))
+			m.overhead.bytes.Add(bytes2 - bytes)
+			m.overhead.samples.Add(1)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package memoryguard
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// threadUsage returns the CPU time used, and the bytes read (rchar from /proc/thread-self/io), by the
+// calling thread, or an error. The bytes include those read from io itself.
+func threadUsage() (time.Duration, int64, error) {
+	var ru unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_THREAD, &ru); err != nil {
+		return 0, 0, err
+	}
+	cpu := time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+
+	io, err := os.ReadFile(DefaultProcRoot + "/thread-self/io")
+	if err != nil {
+		return 0, 0, err
+	}
+	for line := range bytes.Lines(io) {
+		if value, found := bytes.CutPrefix(line, []byte("rchar:")); found {
+			rchar, err := strconv.ParseInt(string(bytes.TrimSpace(value)), 10, 64)
+			return cpu, rchar, err
+		}
+	}
+	return 0, 0, OverheadUnavailableError
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package memoryguard
+
+import "time"
+
+// threadUsage returns OverheadUnavailableError, as per-thread usage is only read on Linux.
+func threadUsage() (time.Duration, int64, error) {
+	return 0, 0, OverheadUnavailableError
+}
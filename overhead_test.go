@@ -0,0 +1,34 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardOverhead(t *testing.T) {
+	Convey("When a MemoryGuard tracking its overhead samples us", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithTrackOverhead())
+		for range 3 {
+			_, err := mg.ForceSample()
+			So(err, ShouldBeNil)
+		}
+
+		Convey("the cost of every sample is added up", func() {
+			o := mg.Overhead()
+			So(o.Samples, ShouldEqual, 3)
+			So(o.BytesRead, ShouldBeGreaterThan, 0)
+			So(o.CPUTime, ShouldBeGreaterThanOrEqualTo, 0)
+		})
+	})
+
+	Convey("When a MemoryGuard not tracking its overhead samples us, there is none", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		_, err := mg.ForceSample()
+		So(err, ShouldBeNil)
+		So(mg.Overhead(), ShouldResemble, Overhead{})
+	})
+}
@@ -0,0 +1,40 @@
+package memoryguard
+
+import "time"
+
+// overrunSamples is the number of consecutive samples that must take longer than Interval to be Overrunning
+const overrunSamples = 3
+
+// Overrunning returns true if sampling has consistently taken longer than Interval, in which case the Limit goro
+// is sampling back-to-back, and pegging a CPU, unless AdaptInterval is set.
+func (m *MemoryGuard) Overrunning() bool {
+	return m.overrunning.Load()
+}
+
+// overran notes that a sample took d, tracking whether we are Overrunning, and returns the Interval to wait
+// before the next sample, measured from now, if AdaptInterval is set and we are, or else 0.
+// Must only be called from the Limit goro.
+func (m *MemoryGuard) overran(name string, d time.Duration) time.Duration {
+	c := m.conf()
+	if c.tick != nil || c.interval <= 0 {
+		return 0
+	} else if d <= c.interval {
+		m.overruns = 0
+		if m.overrunning.CompareAndSwap(true, false) {
+			m.logf(LevelDebug, []any{"name", name, "latency", d, "interval", c.interval}, "[%s] MemoryGuard sampling is no longer overrunning the Interval of %s\n", name, c.interval)
+		}
+		return 0
+	}
+
+	m.overruns++
+	if m.overruns < overrunSamples {
+		return 0
+	} else if m.overrunning.CompareAndSwap(false, true) {
+		m.logf(LevelError, []any{"name", name, "latency", d, "interval", c.interval}, "[%s] MemoryGuard WARNING: sampling is taking %s, longer than the Interval of %s\n", name, d, c.interval)
+	}
+	if !c.adaptInterval {
+		return 0
+	}
+	// Spend at most half the time sampling
+	return max(c.interval, d)
+}
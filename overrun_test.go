@@ -0,0 +1,42 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardOverrunning(t *testing.T) {
+	Convey("When sampling consistently takes longer than the Interval", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithInterval(100*time.Millisecond))
+
+		So(mg.overran("bob", 300*time.Millisecond), ShouldEqual, 0)
+		So(mg.overran("bob", 300*time.Millisecond), ShouldEqual, 0)
+		So(mg.Overrunning(), ShouldBeFalse)
+		So(mg.overran("bob", 300*time.Millisecond), ShouldEqual, 0)
+		So(mg.Overrunning(), ShouldBeTrue)
+
+		Convey("a timely sample ends it", func() {
+			So(mg.overran("bob", 50*time.Millisecond), ShouldEqual, 0)
+			So(mg.Overrunning(), ShouldBeFalse)
+		})
+
+		Convey("with AdaptInterval, the Interval is lengthened", func() {
+			mg.AdaptInterval = true
+			So(mg.overran("bob", 300*time.Millisecond), ShouldEqual, 300*time.Millisecond)
+			So(mg.overran("bob", 50*time.Millisecond), ShouldEqual, 0)
+		})
+	})
+
+	Convey("When a MemoryGuard has a TickSource, it is never overrunning", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithInterval(time.Millisecond), WithTickSource(make(chan time.Time)))
+		for range overrunSamples {
+			So(mg.overran("bob", time.Second), ShouldEqual, 0)
+		}
+		So(mg.Overrunning(), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,63 @@
+package memoryguard
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// pidfd is a Linux process file descriptor, which refers to a specific process instance,
+// so signalling it can't race with PID reuse.
+type pidfd struct {
+	lock sync.Mutex
+	fd   int
+	open bool
+}
+
+// openPidfd opens a pidfd for pid. Returns an error if the kernel doesn't support them (Linux < 5.3).
+func (p *pidfd) openPidfd(pid int) error {
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.fd = fd
+	p.open = true
+	return nil
+}
+
+// signal sends sig via the pidfd, returning false if the pidfd isn't open, or the kernel doesn't
+// support pidfd_send_signal, in which case the caller should fall back.
+func (p *pidfd) signal(sig os.Signal) (bool, error) {
+	ssig, ok := sig.(syscall.Signal)
+	if !ok {
+		return false, nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.open {
+		return false, nil
+	}
+
+	err := unix.PidfdSendSignal(p.fd, ssig, nil, 0)
+	if errors.Is(err, unix.ENOSYS) {
+		return false, nil
+	}
+	return true, err
+}
+
+// close closes the pidfd, if it is open.
+func (p *pidfd) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.open {
+		unix.Close(p.fd)
+		p.open = false
+	}
+}
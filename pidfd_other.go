@@ -0,0 +1,21 @@
+//go:build !linux
+
+package memoryguard
+
+import "os"
+
+// pidfd is a placeholder where there are no process file descriptors.
+type pidfd struct{}
+
+// openPidfd returns PidfdUnavailableError, as pidfds are only available on Linux.
+func (p *pidfd) openPidfd(pid int) error {
+	return PidfdUnavailableError
+}
+
+// signal returns false, so the caller falls back to Process.Signal.
+func (p *pidfd) signal(sig os.Signal) (bool, error) {
+	return false, nil
+}
+
+// close does nothing.
+func (p *pidfd) close() {}
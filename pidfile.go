@@ -0,0 +1,145 @@
+package memoryguard
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PidfileGuard guards whichever process a pidfile names, following it across restarts: when the pidfile
+// names a new PID, the current MemoryGuard is cancelled, and a new one is started for the new process.
+// Samples, Subscribe, and Kills follow every process, so subscribe to them rather than to a Guard.
+type PidfileGuard struct {
+	*relay
+
+	path string
+	max  int64
+	opts []Option
+
+	lock  sync.Mutex
+	guard *MemoryGuard
+	pid   int
+
+	cancel chan struct{}
+	done   chan struct{}
+}
+
+// NewPidfileGuard takes the path to a pidfile, the max usage (in Bytes), how often to poll the pidfile,
+// and optional Options for each MemoryGuard, and returns a running PidfileGuard, or an error if the pidfile
+// can't be read, or the first MemoryGuard can't be started. Close it when done.
+func NewPidfileGuard(path string, max int64, poll time.Duration, opts ...Option) (*PidfileGuard, error) {
+	pid, err := readPidfile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PidfileGuard{
+		relay:  newRelay(opts),
+		path:   path,
+		max:    max,
+		opts:   opts,
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := p.retarget(pid); err != nil {
+		return nil, err
+	}
+
+	go p.follow(poll)
+	return p, nil
+}
+
+// Guard returns the current MemoryGuard. It changes when the pidfile does, so don't hold on to it, nor its
+// KillChan, Samples, or Subscribe.
+func (p *PidfileGuard) Guard() *MemoryGuard {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.guard
+}
+
+// Pid returns the PID currently being guarded.
+func (p *PidfileGuard) Pid() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.pid
+}
+
+// Close stops following the pidfile, cancels the current MemoryGuard, and closes every Samples, Subscribe,
+// and Kills channel. It always returns nil.
+func (p *PidfileGuard) Close() error {
+	select {
+	case <-p.cancel:
+		// already closed
+	default:
+		close(p.cancel)
+	}
+	<-p.done
+
+	p.Guard().CancelWait()
+	p.relay.close()
+	return nil
+}
+
+// follow polls the pidfile until Close. A PID must be read twice in a row before we retarget, so that a
+// pidfile caught mid-rewrite (empty, or partially written) is ignored.
+func (p *PidfileGuard) follow(poll time.Duration) {
+	defer close(p.done)
+
+	var candidate int
+	for {
+		select {
+		case <-p.cancel:
+			return
+		case <-p.clock.After(poll):
+		}
+
+		pid, err := readPidfile(p.path)
+		if err != nil || pid == p.Pid() {
+			candidate = 0
+			continue
+		} else if pid != candidate {
+			candidate = pid
+			continue
+		}
+
+		candidate = 0
+		if err := p.retarget(pid); err != nil {
+			g := p.Guard()
+			g.logf(LevelError, []any{"name", g.name(), "pid", pid, "error", err}, "[%s] MemoryGuard pidfile retarget to %d Error: %s\n", g.name(), pid, err)
+		}
+	}
+}
+
+// retarget cancels the current MemoryGuard, if any, and starts a new one for pid.
+func (p *PidfileGuard) retarget(pid int) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	g := New(proc, p.opts...)
+	if err := p.limit(g, p.max); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	old := p.guard
+	p.guard, p.pid = g, pid
+	p.lock.Unlock()
+
+	if old != nil {
+		old.CancelWait()
+	}
+	return nil
+}
+
+// readPidfile returns the PID in the pidfile at path, or an error.
+func readPidfile(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(bytes.TrimSpace(b)))
+}
@@ -0,0 +1,83 @@
+package memoryguard
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_PidfileGuard(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a PidfileGuard follows a pidfile that is rewritten", t, func() {
+		first, second := exec.Command("sleep", "30"), exec.Command("sleep", "30")
+		So(first.Start(), ShouldBeNil)
+		So(second.Start(), ShouldBeNil)
+		defer func() {
+			first.Process.Kill()
+			second.Process.Kill()
+			first.Wait()
+			second.Wait()
+		}()
+
+		pidfile := filepath.Join(t.TempDir(), "thing.pid")
+		os.WriteFile(pidfile, []byte(strconv.Itoa(first.Process.Pid)+"\n"), 0644)
+
+		p, err := NewPidfileGuard(pidfile, 1024*1024*1024, time.Millisecond, WithInterval(time.Millisecond))
+		So(err, ShouldBeNil)
+		defer p.Close()
+		So(p.Pid(), ShouldEqual, first.Process.Pid)
+		firstGuard := p.Guard()
+		samples := p.Samples()
+
+		os.WriteFile(pidfile, []byte(""), 0644) // mid-rewrite
+		time.Sleep(10 * time.Millisecond)
+		So(p.Pid(), ShouldEqual, first.Process.Pid)
+
+		os.WriteFile(pidfile, []byte(strconv.Itoa(second.Process.Pid)+"\n"), 0644)
+		for p.Pid() != second.Process.Pid {
+			time.Sleep(time.Millisecond)
+		}
+		<-firstGuard.Done()
+		So(p.Guard() != firstGuard, ShouldBeTrue)
+		So(p.Guard().proc.Pid, ShouldEqual, second.Process.Pid)
+
+		// Samples of the second process arrive on the same subscription, which Close closes
+		for range len(samples) + 1 {
+			<-samples
+		}
+		p.Close()
+		for range samples {
+		}
+	})
+
+	Convey("When a PidfileGuard kills the process a pidfile names", t, func() {
+		cmd := exec.Command("sleep", "30")
+		So(cmd.Start(), ShouldBeNil)
+		defer cmd.Wait()
+		defer cmd.Process.Kill()
+
+		pidfile := filepath.Join(t.TempDir(), "thing.pid")
+		os.WriteFile(pidfile, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0644)
+
+		p, err := NewPidfileGuard(pidfile, 1, time.Millisecond, WithInterval(time.Millisecond), WithSkipLimitCheck())
+		So(err, ShouldBeNil)
+		defer p.Close()
+		kills := p.Kills()
+
+		e := <-kills
+		So(e.Trigger, ShouldEqual, TriggerLimit)
+		So(e.Limit, ShouldEqual, 1)
+	})
+
+	Convey("When a PidfileGuard is given a missing pidfile", t, func() {
+		_, err := NewPidfileGuard(filepath.Join(t.TempDir(), "nope.pid"), 1024, time.Millisecond)
+		So(err, ShouldNotBeNil)
+	})
+}
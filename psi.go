@@ -0,0 +1,137 @@
+package memoryguard
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// PSIField selects which memory pressure stall information average to compare against PSIThreshold.
+type PSIField int
+
+const (
+	// PSISomeAvg10 is the share of time in the last 10 seconds that some tasks were stalled on memory
+	PSISomeAvg10 PSIField = iota
+	// PSISomeAvg60 is the share of time in the last 60 seconds that some tasks were stalled on memory
+	PSISomeAvg60
+	// PSISomeAvg300 is the share of time in the last 300 seconds that some tasks were stalled on memory
+	PSISomeAvg300
+	// PSIFullAvg10 is the share of time in the last 10 seconds that all non-idle tasks were stalled on memory
+	PSIFullAvg10
+	// PSIFullAvg60 is the share of time in the last 60 seconds that all non-idle tasks were stalled on memory
+	PSIFullAvg60
+	// PSIFullAvg300 is the share of time in the last 300 seconds that all non-idle tasks were stalled on memory
+	PSIFullAvg300
+)
+
+// PSI is the memory pressure stall information, as percentages.
+type PSI struct {
+	SomeAvg10  float64
+	SomeAvg60  float64
+	SomeAvg300 float64
+	FullAvg10  float64
+	FullAvg60  float64
+	FullAvg300 float64
+}
+
+// Get returns the value of the PSIField
+func (p PSI) Get(f PSIField) float64 {
+	switch f {
+	case PSISomeAvg10:
+		return p.SomeAvg10
+	case PSISomeAvg60:
+		return p.SomeAvg60
+	case PSISomeAvg300:
+		return p.SomeAvg300
+	case PSIFullAvg10:
+		return p.FullAvg10
+	case PSIFullAvg60:
+		return p.FullAvg60
+	case PSIFullAvg300:
+		return p.FullAvg300
+	}
+	return 0
+}
+
+// ReadPSI reads memory pressure stall information from path, which is usually /proc/pressure/memory
+// or a cgroup v2 memory.pressure file. PSI requires Linux 4.20 or later, built with CONFIG_PSI
+// (and not booted with psi=0).
+func ReadPSI(path string) (PSI, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return PSI{}, err
+	}
+	defer f.Close()
+
+	var (
+		psi  PSI
+		some = []byte("some ")
+		full = []byte("full ")
+	)
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Bytes()
+		var avgs [3]*float64
+		if bytes.HasPrefix(line, some) {
+			avgs = [3]*float64{&psi.SomeAvg10, &psi.SomeAvg60, &psi.SomeAvg300}
+		} else if bytes.HasPrefix(line, full) {
+			avgs = [3]*float64{&psi.FullAvg10, &psi.FullAvg60, &psi.FullAvg300}
+		} else {
+			continue
+		}
+
+		for _, field := range bytes.Fields(line[5:]) {
+			key, value, found := bytes.Cut(field, []byte("="))
+			if !found {
+				return PSI{}, PSIFormatError
+			}
+			var i int
+			switch string(key) {
+			case "avg10":
+				i = 0
+			case "avg60":
+				i = 1
+			case "avg300":
+				i = 2
+			default:
+				continue
+			}
+			*avgs[i], err = strconv.ParseFloat(string(value), 64)
+			if err != nil {
+				return PSI{}, err
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return PSI{}, err
+	}
+
+	return psi, nil
+}
+
+// psiPath returns the PSIPath, or the system memory pressure file under the procfs root if it is unset.
+func (c *config) psiPath() string {
+	if c.psiFile != "" {
+		return c.psiFile
+	}
+	return filepath.Join(c.procRoot, "pressure", "memory")
+}
+
+// psiExceeded returns true if PSIThreshold is set, and the configured PSIField is above it.
+// Errors reading PSI are logged, and treated as not exceeded.
+func (m *MemoryGuard) psiExceeded() bool {
+	c := m.conf()
+	if c.psiThreshold <= 0 {
+		return false
+	}
+
+	psi, err := ReadPSI(c.psiPath())
+	if err != nil {
+		m.logf(LevelError, []any{"name", m.name(), "error", err}, "[%s] MemoryGuard PSI Error: %s\n", m.name(), err)
+		return false
+	}
+	return psi.Get(c.psiField) > c.psiThreshold
+}
@@ -0,0 +1,106 @@
+package memoryguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testPSILow  = "some avg10=0.00 avg60=0.10 avg300=0.20 total=1234\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=12\n"
+	testPSIHigh = "some avg10=42.50 avg60=20.00 avg300=5.00 total=1234567\nfull avg10=30.25 avg60=10.00 avg300=2.00 total=123456\n"
+)
+
+func Test_ReadPSI(t *testing.T) {
+	Convey("When PSI is read from a pressure file", t, func() {
+		path := filepath.Join(t.TempDir(), "memory")
+		os.WriteFile(path, []byte(testPSIHigh), 0644)
+		psi, err := ReadPSI(path)
+
+		Convey("all of the averages are parsed", func() {
+			So(err, ShouldBeNil)
+			So(psi.Get(PSISomeAvg10), ShouldEqual, 42.5)
+			So(psi.Get(PSISomeAvg60), ShouldEqual, 20)
+			So(psi.Get(PSISomeAvg300), ShouldEqual, 5)
+			So(psi.Get(PSIFullAvg10), ShouldEqual, 30.25)
+			So(psi.Get(PSIFullAvg60), ShouldEqual, 10)
+			So(psi.Get(PSIFullAvg300), ShouldEqual, 2)
+		})
+	})
+
+	Convey("When PSI is read from a garbage pressure file", t, func() {
+		path := filepath.Join(t.TempDir(), "memory")
+		os.WriteFile(path, []byte("some avg10\n"), 0644)
+		_, err := ReadPSI(path)
+
+		Convey("it returns an error", func() {
+			So(err, ShouldEqual, PSIFormatError)
+		})
+	})
+}
+
+func Test_MemoryGuardPSI(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard has a PSIThreshold", t, func() {
+		path := filepath.Join(t.TempDir(), "memory")
+
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us, WithPSI(25, PSISomeAvg10, false))
+		mg.PSIPath = path
+		mg.SimulateChan = sim
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+
+		Convey("and pressure is high, it kills even though usage is under the limit", func() {
+			os.WriteFile(path, []byte(testPSIHigh), 0644)
+			So(mg.Limit(1000), ShouldBeNil)
+			defer mg.Cancel()
+
+			sim <- 500
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerPSI)
+		})
+
+		Convey("and PSICombined is set, and pressure is low, it doesn't kill even though usage is over the limit", func() {
+			os.WriteFile(path, []byte(testPSILow), 0644)
+			mg.PSICombined = true
+			So(mg.Limit(1000), ShouldBeNil)
+			defer mg.Cancel()
+
+			sim <- 2000
+			sim <- 500 // the second value can't be received until the first is processed
+			So(mg.running.Load(), ShouldBeTrue)
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+		})
+	})
+
+	Convey("When a simulating MemoryGuard with PSICombined and a GrowthWindow runs away, and pressure is low", t, func() {
+		path := filepath.Join(t.TempDir(), "memory")
+		os.WriteFile(path, []byte(testPSILow), 0644)
+		clock := &stepClock{}
+		clock.now.Store(time.Now().UnixNano())
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithClock(clock), WithPSI(25, PSISomeAvg10, true), WithGrowth(time.Minute, 0.5))
+		mg.PSIPath = path
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("it kills for the growth, as only the limit waits for pressure", func() {
+			for range 8 {
+				mg.SimulateChan <- 1000
+				clock.step(10 * time.Second)
+			}
+			mg.SimulateChan <- 3000
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerGrowth)
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package memoryguard
+
+import "sync"
+
+// relay republishes the Samples, GuardStats, and KillEvents of each MemoryGuard that a PidfileGuard or
+// ServiceGuard starts in turn, so that callers can subscribe once, and keep receiving across restarts.
+type relay struct {
+	root       string
+	clock      Clock
+	buffer     int
+	dropOldest bool
+
+	samples hub[Sample]
+	stats   hub[GuardStats]
+	kills   hub[*KillEvent]
+	wg      sync.WaitGroup
+}
+
+// newRelay returns a relay for MemoryGuards configured with opts.
+func newRelay(opts []Option) *relay {
+	m := applied(opts)
+	r := relay{root: m.procRoot(), clock: m.clock(), buffer: m.SampleBuffer, dropOldest: m.SampleDropOldest}
+	if r.buffer <= 0 {
+		r.buffer = DefaultSampleBuffer
+	}
+	return &r
+}
+
+// limit subscribes to g, and calls Limit(max) on it, republishing from it until its Limit goro exits.
+// Returns the error from Limit, if any.
+func (r *relay) limit(g *MemoryGuard, max int64) error {
+	samples := g.Samples()
+	stats, unsubscribe := g.Subscribe(1)
+	if err := g.Limit(max); err != nil {
+		g.samples.unsubscribe(samples)
+		unsubscribe()
+		return err
+	}
+
+	r.wg.Add(1)
+	go r.forward(g, samples, stats)
+	return nil
+}
+
+// forward republishes from samples and stats until both are closed by g's Limit goro exiting, and then
+// g's KillEvent, if it was killed.
+func (r *relay) forward(g *MemoryGuard, samples <-chan Sample, stats <-chan GuardStats) {
+	defer r.wg.Done()
+
+	for samples != nil || stats != nil {
+		select {
+		case s, ok := <-samples:
+			if !ok {
+				samples = nil
+				continue
+			}
+			r.samples.publish(s, r.dropOldest)
+		case s, ok := <-stats:
+			if !ok {
+				stats = nil
+				continue
+			}
+			r.stats.publish(s, true)
+		}
+	}
+
+	if g.WasKilled() {
+		r.kills.publish(g.KillEvent(), false)
+	}
+}
+
+// close waits for every MemoryGuard to be forwarded from, which must already be stopped, and then closes
+// every subscription.
+func (r *relay) close() {
+	r.wg.Wait()
+	r.samples.close()
+	r.stats.close()
+	r.kills.close()
+}
+
+// Samples returns a new channel that receives every successful sample of every guarded process, as
+// MemoryGuard.Samples does, and is closed by Close.
+func (r *relay) Samples() <-chan Sample {
+	return r.samples.subscribe(r.buffer)
+}
+
+// Subscribe returns a new channel that receives the GuardStats of every guarded process, as
+// MemoryGuard.Subscribe does, and a func that unsubscribes and closes it. It is also closed by Close.
+func (r *relay) Subscribe(buffer int) (<-chan GuardStats, func()) {
+	c := r.stats.subscribe(max(buffer, 1))
+	return c, func() { r.stats.unsubscribe(c) }
+}
+
+// Kills returns a new channel that receives the KillEvent of every guarded process that is killed, and is
+// closed by Close. Use it instead of each MemoryGuard's KillChan, which is replaced whenever the process is.
+func (r *relay) Kills() <-chan *KillEvent {
+	return r.kills.subscribe(r.buffer)
+}
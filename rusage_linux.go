@@ -0,0 +1,21 @@
+package memoryguard
+
+import (
+	"os"
+	"syscall"
+)
+
+// getMaxRssInfo returns a SampleInfo for the peak resident set size of pid from getrusage(RUSAGE_SELF), or
+// MaxRSSSelfError if pid isn't us, as getrusage(2) can't say anything about another running process.
+// On Linux, Maxrss is in kilobytes.
+func getMaxRssInfo(pid int) (SampleInfo, error) {
+	if pid != os.Getpid() {
+		return SampleInfo{}, MaxRSSSelfError
+	}
+
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return SampleInfo{}, err
+	}
+	return SampleInfo{Bytes: ru.Maxrss * 1024, Source: "getrusage(RUSAGE_SELF)"}, nil
+}
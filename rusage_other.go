@@ -0,0 +1,8 @@
+//go:build !linux
+
+package memoryguard
+
+// getMaxRssInfo returns RusageUnavailableError, as Maxrss isn't reliably in kilobytes elsewhere.
+func getMaxRssInfo(pid int) (SampleInfo, error) {
+	return SampleInfo{}, RusageUnavailableError
+}
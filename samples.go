@@ -0,0 +1,116 @@
+package memoryguard
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSampleBuffer is the default SampleBuffer
+const DefaultSampleBuffer = 16
+
+// Sample is a single successful reading of the process's memory usage.
+type Sample struct {
+	// Time is when the sample was taken
+	Time time.Time
+	// Value is the sampled value of the Metric, in Bytes
+	Value int64
+	// Limit is the limit in effect when the sample was taken
+	Limit int64
+}
+
+// hub fans values out to subscribers, never blocking the publisher.
+type hub[T any] struct {
+	lock    sync.Mutex
+	subs    []chan T
+	closed  bool
+	dropped atomic.Int64
+}
+
+// subscribe returns a new channel with the buffer size, or a closed one if the hub is closed.
+func (h *hub[T]) subscribe(buffer int) <-chan T {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	c := make(chan T, buffer)
+	if h.closed {
+		close(c)
+		return c
+	}
+	h.subs = append(h.subs, c)
+	return c
+}
+
+// unsubscribe removes and closes the subscriber c, if it is still subscribed.
+func (h *hub[T]) unsubscribe(c <-chan T) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for i, sub := range h.subs {
+		if sub == c {
+			close(sub)
+			h.subs = slices.Delete(h.subs, i, i+1)
+			return
+		}
+	}
+}
+
+// publish sends v to every subscriber without blocking. If a subscriber's buffer is full, either
+// v is dropped, or if dropOldest, the oldest buffered value is dropped to make room for v.
+func (h *hub[T]) publish(v T, dropOldest bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, c := range h.subs {
+		select {
+		case c <- v:
+			continue
+		default:
+		}
+
+		h.dropped.Add(1)
+		if !dropOldest {
+			continue
+		}
+		select {
+		case <-c:
+		default:
+		}
+		select {
+		case c <- v:
+		default:
+		}
+	}
+}
+
+// close closes every subscriber, and prevents new ones.
+func (h *hub[T]) close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for _, c := range h.subs {
+		close(c)
+	}
+	h.subs = nil
+}
+
+// Samples returns a new channel that receives every successful sample taken by the Limit goro, and is closed
+// when it exits. Each call returns a separate subscription. Sends never block the Limit goro: if the
+// subscription's buffer (SampleBuffer) is full, a sample is dropped per SampleDropOldest.
+func (m *MemoryGuard) Samples() <-chan Sample {
+	buffer := m.conf().sampleBuffer
+	if buffer <= 0 {
+		buffer = DefaultSampleBuffer
+	}
+	return m.samples.subscribe(buffer)
+}
+
+// SamplesDropped returns the number of samples dropped across all subscriptions because their buffers were full.
+func (m *MemoryGuard) SamplesDropped() int64 {
+	return m.samples.dropped.Load()
+}
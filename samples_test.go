@@ -0,0 +1,104 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardSamples(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard has two Samples subscribers", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us)
+		mg.SimulateChan = sim
+		mg.SampleBuffer = 2
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		a := mg.Samples()
+		b := mg.Samples()
+
+		Convey("both get every sample, until their buffers fill, and both are closed when the guard exits", func() {
+			sim <- 100
+			So((<-a).Value, ShouldEqual, 100)
+			So((<-b).Value, ShouldEqual, 100)
+
+			sim <- 200
+			sim <- 300
+			sim <- 400
+			sim <- 500 // the fifth value can't be received until the fourth is processed
+			mg.CancelWait()
+
+			So((<-a).Value, ShouldEqual, 200)
+			So((<-a).Value, ShouldEqual, 300)
+			_, open := <-a
+			So(open, ShouldBeFalse)
+			So(mg.SamplesDropped(), ShouldBeGreaterThanOrEqualTo, 2)
+
+			_, open = <-mg.Samples()
+			So(open, ShouldBeFalse)
+		})
+	})
+
+	Convey("When a simulating MemoryGuard has a Samples subscriber, and drops the oldest", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		sim := make(chan int64)
+		mg := New(us)
+		mg.SimulateChan = sim
+		mg.SampleBuffer = 1
+		mg.SampleDropOldest = true
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		a := mg.Samples()
+
+		Convey("the subscriber gets the latest sample", func() {
+			sim <- 100
+			sim <- 200
+			sim <- 300
+			mg.CancelWait()
+
+			So((<-a).Value, ShouldEqual, 300)
+		})
+	})
+}
+
+func Test_NewMonitor(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a monitor is running on us", t, func() {
+		var samples = make(chan Sample, 10)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg, err := NewMonitor(us, time.Millisecond, func(s Sample) {
+			select {
+			case samples <- s:
+			default:
+			}
+		})
+		So(err, ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("it calls back with samples, and has no limit", func() {
+			for range 3 {
+				s := <-samples
+				So(s.Value, ShouldBeGreaterThan, 0)
+				So(s.Limit, ShouldEqual, 0)
+			}
+			So(mg.running.Load(), ShouldBeTrue)
+			So(mg.EffectiveLimit(), ShouldEqual, 0)
+			So(mg.Limit(1024), ShouldEqual, LimitOnceError)
+		})
+	})
+
+	Convey("When a monitor is created with a nil process, it refuses", t, func() {
+		_, err := NewMonitor(nil, time.Millisecond, func(Sample) {})
+		So(err, ShouldEqual, LimitNilProcessError)
+	})
+}
@@ -0,0 +1,68 @@
+package memoryguard
+
+import (
+	"slices"
+	"time"
+)
+
+// ScheduleWindow is a window of the day, on some or all days of the week, with its own limit, for LimitSchedule.
+type ScheduleWindow struct {
+	// Start is when the window opens, as an offset from midnight, e.g. 9*time.Hour
+	Start time.Duration
+	// End is when the window closes, as an offset from midnight. If it is before Start, the window wraps past
+	// midnight, e.g. a Start of 22*time.Hour and an End of 6*time.Hour is overnight.
+	End time.Duration
+	// Days are the days of the week the window opens on, or every day if empty
+	Days []time.Weekday
+	// Limit is the limit in Bytes while the window is open
+	Limit int64
+}
+
+// contains returns true if t, in its own Location, is within the window.
+func (w ScheduleWindow) contains(t time.Time) bool {
+	h, mi, s := t.Clock()
+	since := time.Duration(h)*time.Hour + time.Duration(mi)*time.Minute + time.Duration(s)*time.Second + time.Duration(t.Nanosecond())
+	day := t.Weekday()
+
+	if w.Start <= w.End {
+		return since >= w.Start && since < w.End && w.on(day)
+	} else if since >= w.Start {
+		// Opened today
+		return w.on(day)
+	} else if since < w.End {
+		// Opened yesterday
+		return w.on((day + 6) % 7)
+	}
+	return false
+}
+
+// on returns true if the window opens on day.
+func (w ScheduleWindow) on(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, day)
+}
+
+// ScheduledLimit returns the limit currently in effect per LimitSchedule: the limit given to SetLimit() if it
+// has been called (until ClearLimit), or else the Limit of the first open ScheduleWindow, or else the limit
+// given to Limit(). It doesn't include any BoostLimit (see EffectiveLimit),
+// and is 0 if there is no absolute limit.
+func (m *MemoryGuard) ScheduledLimit() int64 {
+	if l := m.limit.Load(); l > 0 && l != unknownLimit && l != monitorLimit {
+		return m.scheduled(m.graced(l))
+	}
+	return 0
+}
+
+// scheduled returns the Limit of the first open ScheduleWindow, or else l. Relative limits, and those from
+// SetLimit, are never scheduled.
+func (m *MemoryGuard) scheduled(l int64) int64 {
+	if m.delta > 0 || m.original.Load() != 0 {
+		return l
+	}
+	now := m.now()
+	for _, w := range m.conf().limitSchedule {
+		if w.contains(now) {
+			return w.Limit
+		}
+	}
+	return l
+}
@@ -0,0 +1,104 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ScheduleWindow(t *testing.T) {
+	Convey("When a daytime ScheduleWindow is checked", t, func() {
+		w := ScheduleWindow{Start: 9 * time.Hour, End: 17 * time.Hour, Days: []time.Weekday{time.Thursday}}
+		So(w.contains(time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)), ShouldBeTrue) // Thursday
+		So(w.contains(time.Date(2026, 10, 15, 16, 59, 59, 0, time.UTC)), ShouldBeTrue)
+		So(w.contains(time.Date(2026, 10, 15, 17, 0, 0, 0, time.UTC)), ShouldBeFalse)
+		So(w.contains(time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)), ShouldBeFalse)
+		So(w.contains(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)), ShouldBeFalse) // Friday
+	})
+
+	Convey("When an overnight ScheduleWindow is checked", t, func() {
+		w := ScheduleWindow{Start: 22 * time.Hour, End: 6 * time.Hour, Days: []time.Weekday{time.Thursday}}
+		So(w.contains(time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC)), ShouldBeTrue) // Thursday night
+		So(w.contains(time.Date(2026, 10, 16, 5, 0, 0, 0, time.UTC)), ShouldBeTrue)  // opened Thursday
+		So(w.contains(time.Date(2026, 10, 15, 5, 0, 0, 0, time.UTC)), ShouldBeFalse) // opened Wednesday
+		So(w.contains(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)), ShouldBeFalse)
+	})
+}
+
+func Test_MemoryGuardLimitSchedule(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard has a looser limit during the day", t, func() {
+		clock := &stepClock{}
+		clock.now.Store(time.Date(2026, 10, 15, 10, 0, 0, 0, time.Local).UnixNano())
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithClock(clock), WithLimitSchedule(ScheduleWindow{Start: 9 * time.Hour, End: 17 * time.Hour, Limit: 5000}))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.ScheduledLimit(), ShouldEqual, 0)
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		Convey("it isn't killed during the day, but is in the evening", func() {
+			So(mg.ScheduledLimit(), ShouldEqual, 5000)
+			So(mg.EffectiveLimit(), ShouldEqual, 5000)
+			mg.SimulateChan <- 2000
+			mg.SimulateChan <- 2000
+			mg.SimulateChan <- 500 // the third value can't be received until the second is processed
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+
+			clock.step(8 * time.Hour)
+			So(mg.ScheduledLimit(), ShouldEqual, 1000)
+			mg.SimulateChan <- 2000
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerLimit)
+			So(mg.KillEvent().Limit, ShouldEqual, 1000)
+		})
+	})
+
+	Convey("When a simulating MemoryGuard with a looser limit during the day has its limit set", t, func() {
+		clock := &stepClock{}
+		clock.now.Store(time.Date(2026, 10, 15, 10, 0, 0, 0, time.Local).UnixNano())
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithClock(clock), WithLimitSchedule(ScheduleWindow{Start: 9 * time.Hour, End: 17 * time.Hour, Limit: 5000}))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		mg.SimulateChan <- 2000
+		mg.SimulateChan <- 2000 // the second value can't be received until the first is processed
+
+		Convey("SetLimit overrides the schedule until ClearLimit", func() {
+			So(mg.SetLimit(3000), ShouldBeNil)
+			So(mg.ScheduledLimit(), ShouldEqual, 3000)
+			So(mg.EffectiveLimit(), ShouldEqual, 3000)
+
+			mg.ClearLimit()
+			So(mg.ScheduledLimit(), ShouldEqual, 5000)
+			clock.step(8 * time.Hour)
+			So(mg.ScheduledLimit(), ShouldEqual, 1000)
+		})
+
+		Convey("lowering it below usage is graced from the window's limit", func() {
+			So(mg.SetLimit(1500), ShouldBeNil)
+			So(mg.EffectiveLimit(), ShouldEqual, 5000)
+			mg.SimulateChan <- 2000
+			mg.SimulateChan <- 2000 // the second value can't be received until the first is processed
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+
+			clock.step(DefaultLowerLimitGrace)
+			So(mg.EffectiveLimit(), ShouldEqual, 1500)
+			select {
+			case mg.SimulateChan <- 2000:
+				<-mg.KillChan // wait for the kill
+			case <-mg.KillChan:
+				// the second value was still being processed
+			}
+			So(mg.KillEvent().Limit, ShouldEqual, 1500)
+		})
+	})
+}
@@ -0,0 +1,27 @@
+package memoryguard
+
+import (
+	"net"
+	"os"
+)
+
+// sdNotify sends state (e.g. "WATCHDOG=1") to systemd via NOTIFY_SOCKET, as sd_notify(3) does.
+// It is a no-op if NOTIFY_SOCKET is unset.
+func sdNotify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	} else if path[0] == '@' {
+		// Abstract namespace
+		path = "\x00" + path[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,42 @@
+package memoryguard
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardSystemdWatchdog(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard with SystemdWatchdog samples under systemd", t, func() {
+		path := filepath.Join(t.TempDir(), "notify")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		t.Setenv("NOTIFY_SOCKET", path)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithSystemdWatchdog())
+		mg.SimulateChan = make(chan int64)
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		mg.SimulateChan <- 100
+		Convey("the watchdog is pinged", func() {
+			buf := make([]byte, 64)
+			n, err := conn.Read(buf)
+			So(err, ShouldBeNil)
+			So(string(buf[:n]), ShouldEqual, "WATCHDOG=1")
+		})
+	})
+
+	Convey("When NOTIFY_SOCKET is unset, notifying is a no-op", t, func() {
+		t.Setenv("NOTIFY_SOCKET", "")
+		So(sdNotify("WATCHDOG=1"), ShouldBeNil)
+	})
+}
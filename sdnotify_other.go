@@ -0,0 +1,8 @@
+//go:build !linux
+
+package memoryguard
+
+// sdNotify is a no-op where there is no systemd.
+func sdNotify(state string) error {
+	return nil
+}
@@ -0,0 +1,187 @@
+package memoryguard
+
+import (
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ServiceSwitch describes a ServiceGuard switching from one instance of the service to the next.
+type ServiceSwitch struct {
+	// From is the PID of the instance that went away
+	From int
+	// To is the PID of the instance now being guarded
+	To int
+	// Cmdline is the command line of the service, with arguments separated by spaces
+	Cmdline string
+	// Time is when the switch happened
+	Time time.Time
+}
+
+// ServiceGuard guards one logical service, matched by command line, following it across restarts. Where
+// several processes match, the one that started first is guarded. When it goes away (including if its PID is
+// reused by another process, which its start time exposes), the guard switches to the first process started
+// after it with the very same command line, e.g. the service's restart by its supervisor. Processes that merely
+// match the pattern, with different arguments, are never switched to. Samples, Subscribe, and Kills follow
+// every instance, so subscribe to them rather than to a Guard.
+type ServiceGuard struct {
+	*relay
+
+	pattern  *regexp.Regexp
+	max      int64
+	opts     []Option
+	onSwitch func(ServiceSwitch)
+
+	lock    sync.Mutex
+	guard   *MemoryGuard
+	pid     int
+	start   time.Duration
+	cmdline string
+
+	cancel chan struct{}
+	done   chan struct{}
+}
+
+// NewServiceGuard takes a pattern to match command lines (with arguments separated by spaces) against, the
+// max usage (in Bytes), how often to scan procfs, an optional func called (on the scanning goro) whenever the
+// guarded instance switches, and optional Options for each MemoryGuard, and returns a running ServiceGuard, or
+// an error if nothing matches, or the first MemoryGuard can't be started. Close it when done.
+func NewServiceGuard(pattern *regexp.Regexp, max int64, scan time.Duration, onSwitch func(ServiceSwitch), opts ...Option) (*ServiceGuard, error) {
+	if max <= 0 {
+		return nil, LimitZeroError
+	}
+
+	s := &ServiceGuard{
+		relay:    newRelay(opts),
+		pattern:  pattern,
+		max:      max,
+		opts:     opts,
+		onSwitch: onSwitch,
+		cancel:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if !procfsAvailable(s.root) {
+		return nil, ProcfsUnavailableError
+	}
+	if err := s.scan(); err != nil {
+		return nil, err
+	}
+
+	go s.follow(scan)
+	return s, nil
+}
+
+// Guard returns the current MemoryGuard. It changes when the service restarts, so don't hold on to it, nor
+// its KillChan, Samples, or Subscribe.
+func (s *ServiceGuard) Guard() *MemoryGuard {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.guard
+}
+
+// Pid returns the PID currently being guarded.
+func (s *ServiceGuard) Pid() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.pid
+}
+
+// Close stops following the service, cancels the current MemoryGuard, and closes every Samples, Subscribe,
+// and Kills channel. It always returns nil.
+func (s *ServiceGuard) Close() error {
+	select {
+	case <-s.cancel:
+		// already closed
+	default:
+		close(s.cancel)
+	}
+	<-s.done
+
+	s.Guard().CancelWait()
+	s.relay.close()
+	return nil
+}
+
+// follow scans every interval until Close.
+func (s *ServiceGuard) follow(interval time.Duration) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.cancel:
+			return
+		case <-s.clock.After(interval):
+		}
+
+		if err := s.scan(); err != nil && err != NoMatchError {
+			g := s.Guard()
+			g.logf(LevelError, []any{"name", g.name(), "error", err}, "[%s] MemoryGuard service retarget Error: %s\n", g.name(), err)
+		}
+	}
+}
+
+// scan does nothing while the guarded instance is alive, and otherwise retargets to its successor, or the
+// first match if there is no guarded instance yet. Returns NoMatchError if there isn't one (yet).
+func (s *ServiceGuard) scan() error {
+	s.lock.Lock()
+	pid, start, cmdline := s.pid, s.start, s.cmdline
+	s.lock.Unlock()
+
+	if pid != 0 {
+		if st, err := procStartTime(s.root, pid); err == nil && st == start && !isGone(s.root, pid) {
+			return nil
+		}
+	}
+
+	var (
+		next   int
+		nstart time.Duration
+	)
+	matches := matchCmdlines(s.root, s.pattern)
+	for p, c := range matches {
+		if pid != 0 && c != cmdline {
+			continue
+		}
+		st, err := procStartTime(s.root, p)
+		if err != nil || (pid != 0 && st <= start) || isGone(s.root, p) {
+			continue
+		}
+		if next == 0 || st < nstart || (st == nstart && p < next) {
+			next, nstart = p, st
+		}
+	}
+	if next == 0 {
+		return NoMatchError
+	}
+	return s.retarget(next, nstart, matches[next])
+}
+
+// retarget cancels the current MemoryGuard, if any, starts a new one for pid, and calls onSwitch if this
+// isn't the first.
+func (s *ServiceGuard) retarget(pid int, start time.Duration, cmdline string) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	g := New(proc, s.opts...)
+	if err := s.limit(g, s.max); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	old, from := s.guard, s.pid
+	s.guard, s.pid, s.start, s.cmdline = g, pid, start, cmdline
+	s.lock.Unlock()
+
+	if old == nil {
+		return nil
+	}
+	old.CancelWait()
+	g.logf(LevelDebug, []any{"name", g.name(), "from", from, "pid", pid}, "[%s] MemoryGuard service switched from %d to %d\n", g.name(), from, pid)
+	if s.onSwitch != nil {
+		s.onSwitch(ServiceSwitch{From: from, To: pid, Cmdline: cmdline, Time: g.now()})
+	}
+	return nil
+}
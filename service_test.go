@@ -0,0 +1,62 @@
+package memoryguard
+
+import (
+	"os/exec"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ServiceGuard(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a ServiceGuard follows a service that restarts, alongside a process that merely matches", t, func() {
+		pattern := regexp.MustCompile(`^sleep 31\.2718`)
+		first := exec.Command("sleep", "31.2718")
+		So(first.Start(), ShouldBeNil)
+		time.Sleep(20 * time.Millisecond) // so the start times differ
+		decoy := exec.Command("sleep", "31.27189")
+		So(decoy.Start(), ShouldBeNil)
+		defer func() {
+			decoy.Process.Kill()
+			decoy.Wait()
+		}()
+
+		switches := make(chan ServiceSwitch, 1)
+		s, err := NewServiceGuard(pattern, 1024*1024*1024, time.Millisecond, func(sw ServiceSwitch) { switches <- sw }, WithInterval(time.Millisecond))
+		So(err, ShouldBeNil)
+		defer s.Close()
+		So(s.Pid(), ShouldEqual, first.Process.Pid)
+		firstGuard := s.Guard()
+
+		Convey("it stays with the first until it is restarted, and then switches to the restart", func() {
+			first.Process.Kill()
+			first.Wait()
+			time.Sleep(10 * time.Millisecond)
+			So(s.Pid(), ShouldEqual, first.Process.Pid)
+
+			second := exec.Command("sleep", "31.2718")
+			So(second.Start(), ShouldBeNil)
+			defer func() {
+				second.Process.Kill()
+				second.Wait()
+			}()
+
+			sw := <-switches
+			So(sw.From, ShouldEqual, first.Process.Pid)
+			So(sw.To, ShouldEqual, second.Process.Pid)
+			So(sw.Cmdline, ShouldEqual, "sleep 31.2718")
+			So(s.Pid(), ShouldEqual, second.Process.Pid)
+			<-firstGuard.Done()
+			So(s.Guard().proc.Pid, ShouldEqual, second.Process.Pid)
+		})
+	})
+
+	Convey("When a ServiceGuard matches nothing, it refuses", t, func() {
+		_, err := NewServiceGuard(regexp.MustCompile(`^no such thing 27\.18$`), 1024, time.Millisecond, nil)
+		So(err, ShouldEqual, NoMatchError)
+	})
+}
@@ -0,0 +1,75 @@
+package memoryguard
+
+import (
+	"time"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// DefaultLowerLimitGrace is the default LowerLimitGrace
+const DefaultLowerLimitGrace = time.Minute
+
+// lowered is the previous limit, still enforced until a SetLimit below the last sample has had its grace.
+type lowered struct {
+	prev  int64
+	until time.Time
+}
+
+// SetLimit replaces the limit (including one computed by LimitRelative) with max, in Bytes, while running.
+// It also overrides any LimitSchedule, whose windows are ignored until ClearLimit. Raising it takes effect at
+// the next sample. Lowering it below the last sample is where care is needed, as the next sample would be over
+// it: unless ImmediateEnforce is set, the limit that was being enforced (whether the previous SetLimit, the
+// open ScheduleWindow's, or that given to Limit()) continues to be for LowerLimitGrace, giving the process time
+// to shed memory, and then the new limit is. Returns an error if max is zero or negative, or there is no limit
+// to replace yet (e.g. a monitor, or LimitRelative without a baseline).
+func (m *MemoryGuard) SetLimit(max int64) error {
+	if max <= 0 {
+		return LimitZeroError
+	}
+
+	var old, prev int64
+	for {
+		old = m.limit.Load()
+		if old == 0 || old == unknownLimit || old == monitorLimit {
+			return LimitNotSetError
+		}
+		prev = m.scheduled(m.graced(old))
+		if m.limit.CompareAndSwap(old, max) {
+			break
+		}
+	}
+	m.original.CompareAndSwap(0, old)
+
+	c, name := m.conf(), m.name()
+	if xss := m.lastPss.Load(); !c.immediateEnforce && max < prev && xss > max {
+		grace := c.lowerLimitGrace
+		if grace <= 0 {
+			grace = DefaultLowerLimitGrace
+		}
+		m.lowered.Store(&lowered{prev: prev, until: m.now().Add(grace)})
+		m.logf(LevelDebug, []any{"name", name, "limit", max, "pss", xss, "grace", grace}, "[%s] MemoryGuard Limit lowered to %s, below %s, enforced in %s\n", name, humanity.ByteFormat(max), humanity.ByteFormat(xss), grace)
+		return nil
+	}
+	m.lowered.Store(nil)
+	m.logf(LevelDebug, []any{"name", name, "limit", max}, "[%s] MemoryGuard Limit set to %s\n", name, humanity.ByteFormat(max))
+	return nil
+}
+
+// ClearLimit undoes SetLimit, restoring the limit given to Limit() (or computed by LimitRelative), and any
+// LimitSchedule, from the next sample. It does nothing if SetLimit hasn't been called since Limit() or the
+// last ClearLimit.
+func (m *MemoryGuard) ClearLimit() {
+	if l := m.original.Swap(0); l != 0 {
+		m.limit.Store(l)
+		m.lowered.Store(nil)
+		m.logf(LevelDebug, []any{"name", m.name(), "limit", l}, "[%s] MemoryGuard Limit restored to %s\n", m.name(), humanity.ByteFormat(l))
+	}
+}
+
+// graced returns the previous limit if SetLimit lowered l within LowerLimitGrace, or else l.
+func (m *MemoryGuard) graced(l int64) int64 {
+	if g := m.lowered.Load(); g != nil && g.prev > l && m.now().Before(g.until) {
+		return g.prev
+	}
+	return l
+}
@@ -0,0 +1,120 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardSetLimit(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard hasn't got a limit, it can't be set", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		So(mg.SetLimit(1000), ShouldEqual, LimitNotSetError)
+		So(mg.SetLimit(0), ShouldEqual, LimitZeroError)
+	})
+
+	Convey("When a simulating MemoryGuard has its limit lowered below its usage", t, func() {
+		clock := &stepClock{}
+		clock.now.Store(time.Now().UnixNano())
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithClock(clock))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		mg.SimulateChan <- 500
+		mg.SimulateChan <- 500 // the second value can't be received until the first is processed
+
+		Convey("raising it takes effect immediately", func() {
+			So(mg.SetLimit(2000), ShouldBeNil)
+			So(mg.EffectiveLimit(), ShouldEqual, 2000)
+		})
+
+		Convey("it is enforced after the grace", func() {
+			So(mg.SetLimit(400), ShouldBeNil)
+			So(mg.EffectiveLimit(), ShouldEqual, 1000)
+			mg.SimulateChan <- 500
+			mg.SimulateChan <- 300 // the second value can't be received until the first is processed
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+
+			clock.step(DefaultLowerLimitGrace)
+			So(mg.EffectiveLimit(), ShouldEqual, 400)
+			select {
+			case mg.SimulateChan <- 500:
+				<-mg.KillChan // wait for the kill
+			case <-mg.KillChan:
+				// the second value was still being processed
+			}
+			So(mg.KillEvent().Limit, ShouldEqual, 400)
+		})
+	})
+
+	Convey("When a simulating MemoryGuard's relative limit is replaced", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithLowerLimitGrace(0))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.LimitRelative(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		mg.SimulateChan <- 500
+		mg.SimulateChan <- 500 // the second value can't be received until the first is processed
+
+		Convey("breaching the new limit is TriggerLimit", func() {
+			So(mg.SetLimit(700), ShouldBeNil)
+			select {
+			case mg.SimulateChan <- 800:
+				<-mg.KillChan // wait for the kill
+			case <-mg.KillChan:
+				// the second value was still being processed
+			}
+			So(mg.KillEvent().Trigger, ShouldEqual, TriggerLimit)
+			So(mg.KillEvent().Limit, ShouldEqual, 700)
+		})
+
+		Convey("once cleared, breaching the relative limit is TriggerRelativeLimit again", func() {
+			So(mg.SetLimit(700), ShouldBeNil)
+			mg.ClearLimit()
+			mg.SimulateChan <- 800
+			select {
+			case mg.SimulateChan <- 1600:
+				<-mg.KillChan // wait for the kill
+			case <-mg.KillChan:
+				// the second value was still being processed
+			}
+			So(mg.KillEvent().Trigger, ShouldEqual, TriggerRelativeLimit)
+			So(mg.KillEvent().Limit, ShouldEqual, 1500)
+		})
+	})
+
+	Convey("When a simulating MemoryGuard with ImmediateEnforce has its limit lowered below its usage", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithLowerLimitGrace(0))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.Cancel()
+
+		mg.SimulateChan <- 500
+		mg.SimulateChan <- 500 // the second value can't be received until the first is processed
+
+		Convey("it is enforced at the next sample", func() {
+			So(mg.SetLimit(400), ShouldBeNil)
+			So(mg.EffectiveLimit(), ShouldEqual, 400)
+			select {
+			case mg.SimulateChan <- 500:
+				<-mg.KillChan // wait for the kill
+			case <-mg.KillChan:
+				// the second value was still being processed
+			}
+			So(mg.KillEvent().Limit, ShouldEqual, 400)
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package memoryguard
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// shmemPrefixes are the pathnames of shared memory mappings that aren't files on a tmpfs mount
+var shmemPrefixes = []string{"/dev/shm/", "/SYSV", "/memfd:", "/dev/zero"}
+
+// Shmem returns true if the Mapping is a shared mapping of shared memory, per MetricPSSShmem.
+// tmpfs are the mount points of the tmpfs filesystems visible to the process.
+func (mp Mapping) Shmem(tmpfs []string) bool {
+	if !strings.HasSuffix(mp.Perms, "s") {
+		return false
+	}
+	for _, pfx := range shmemPrefixes {
+		if strings.HasPrefix(mp.Pathname, pfx) {
+			return true
+		}
+	}
+	for _, mount := range tmpfs {
+		if strings.HasPrefix(mp.Pathname, strings.TrimSuffix(mount, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ShmemPss returns the sum of the Pss of the mappings, except those that are Shmem, which are summed at
+// their Rss, in Bytes. See MetricPSSShmem.
+func ShmemPss(mappings []Mapping, tmpfs []string) int64 {
+	var total int64
+	for _, mp := range mappings {
+		if mp.Shmem(tmpfs) {
+			total += mp.Rss
+		} else {
+			total += mp.Pss
+		}
+	}
+	return total
+}
+
+// getShmemPssInfo takes a procfs root and a pid, and returns a SampleInfo with the ShmemPss, or an error
+func getShmemPssInfo(root string, pid int) (SampleInfo, error) {
+	path := procPath(root, pid, "smaps")
+	f, err := os.Open(path)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	defer f.Close()
+
+	mappings, err := ParseMappings(f)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	return SampleInfo{Bytes: ShmemPss(mappings, tmpfsMounts(root, pid)), Source: path, MappingCount: len(mappings)}, nil
+}
+
+// tmpfsMounts takes a procfs root and a pid, and returns the mount points of the tmpfs filesystems
+// in its mountinfo, or nil if it can't be read.
+func tmpfsMounts(root string, pid int) []string {
+	f, err := os.Open(procPath(root, pid, "mountinfo"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var mounts []string
+	s := newSmapsScanner(f)
+	for s.Scan() {
+		// id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		pre, post, found := strings.Cut(s.Text(), " - ")
+		if !found {
+			continue
+		}
+		fields, fstype := strings.Fields(pre), strings.Fields(post)
+		if len(fields) >= 5 && len(fstype) >= 1 && fstype[0] == "tmpfs" {
+			mounts = append(mounts, unescapeMount(fields[4]))
+		}
+	}
+	return mounts
+}
+
+// unescapeMount undoes the octal escaping (e.g. "\040" for a space) of a mountinfo field.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package memoryguard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Shmem(t *testing.T) {
+	Convey("When mappings are classified as shared memory", t, func() {
+		tmpfs := []string{"/run", "/tmp/my dir"}
+		So(Mapping{Perms: "rw-s", Pathname: "/dev/shm/ipc"}.Shmem(tmpfs), ShouldBeTrue)
+		So(Mapping{Perms: "rw-s", Pathname: "/SYSV00000000 (deleted)"}.Shmem(tmpfs), ShouldBeTrue)
+		So(Mapping{Perms: "rw-s", Pathname: "/memfd:buf (deleted)"}.Shmem(tmpfs), ShouldBeTrue)
+		So(Mapping{Perms: "rw-s", Pathname: "/dev/zero (deleted)"}.Shmem(tmpfs), ShouldBeTrue)
+		So(Mapping{Perms: "rw-s", Pathname: "/run/ring"}.Shmem(tmpfs), ShouldBeTrue)
+		So(Mapping{Perms: "rw-s", Pathname: "/tmp/my dir/ring"}.Shmem(tmpfs), ShouldBeTrue)
+		So(Mapping{Perms: "rw-s", Pathname: "/running/ring"}.Shmem(tmpfs), ShouldBeFalse)
+		So(Mapping{Perms: "rw-p", Pathname: "/dev/shm/ipc"}.Shmem(tmpfs), ShouldBeFalse) // private
+		So(Mapping{Perms: "rw-s", Pathname: "/var/lib/db"}.Shmem(tmpfs), ShouldBeFalse)
+
+		Convey("they are summed at their Rss, and everything else at its Pss", func() {
+			mappings := []Mapping{
+				{Perms: "rw-p", Pathname: "[heap]", Rss: 1000, Pss: 1000},
+				{Perms: "rw-s", Pathname: "/dev/shm/ipc", Rss: 4000, Pss: 1000},
+				{Perms: "r-xp", Pathname: "/usr/lib/libc.so", Rss: 2000, Pss: 200},
+			}
+			So(ShmemPss(mappings, nil), ShouldEqual, 5200)
+		})
+	})
+}
+
+func Test_TmpfsMounts(t *testing.T) {
+	Convey("When the tmpfs mounts are read from a mountinfo", t, func() {
+		root := t.TempDir()
+		os.Mkdir(filepath.Join(root, "1"), 0755)
+		os.WriteFile(filepath.Join(root, "1", "mountinfo"), []byte(strings.Join([]string{
+			"22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw",
+			"23 22 0:21 / /dev/shm rw,nosuid,nodev shared:2 - tmpfs tmpfs rw",
+			`24 22 0:22 / /tmp/my\040dir rw - tmpfs tmpfs rw`,
+			"25 22 0:23 / /proc rw - proc proc rw",
+		}, "\n")), 0644)
+
+		So(tmpfsMounts(root, 1), ShouldResemble, []string{"/dev/shm", "/tmp/my dir"})
+		So(tmpfsMounts(root, 2), ShouldBeNil)
+	})
+}
+
+func Test_GetShmemPssInfo(t *testing.T) {
+	Convey("When the shmem-inclusive PSS of our process is read", t, func() {
+		info, err := getShmemPssInfo(DefaultProcRoot, os.Getpid())
+		So(err, ShouldBeNil)
+		So(info.Bytes, ShouldBeGreaterThan, 0)
+		So(info.MappingCount, ShouldBeGreaterThan, 0)
+	})
+}
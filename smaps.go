@@ -0,0 +1,294 @@
+package memoryguard
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+)
+
+// maxSmapsLine is the longest line of smaps (or mountinfo) we'll parse. A pathological pathname could exceed
+// the 64KB default of bufio.Scanner, which would fail every sample with bufio.ErrTooLong.
+const maxSmapsLine = 1024 * 1024
+
+// newSmapsScanner returns a bufio.Scanner for r whose buffer starts small, but grows as needed up to maxSmapsLine.
+func newSmapsScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxSmapsLine)
+	return s
+}
+
+// SmapsTotals is the sum of the interesting fields across all of the mappings in a
+// /proc/[pid]/smaps file. All values are in Bytes.
+type SmapsTotals struct {
+	Rss            int64
+	Pss            int64
+	SharedClean    int64
+	SharedDirty    int64
+	PrivateClean   int64
+	PrivateDirty   int64
+	Referenced     int64
+	Anonymous      int64
+	AnonHugePages  int64
+	ShmemPmdMapped int64
+	Swap           int64
+	SwapPss        int64
+	Locked         int64
+	SharedHugetlb  int64
+	PrivateHugetlb int64
+	// Mappings is the number of mappings summed
+	Mappings int
+}
+
+// String returns a compact, human-readable version of the totals.
+func (s SmapsTotals) String() string {
+	return fmt.Sprintf("Rss: %d Pss: %d SharedClean: %d SharedDirty: %d PrivateClean: %d PrivateDirty: %d Anonymous: %d Swap: %d SwapPss: %d",
+		s.Rss, s.Pss, s.SharedClean, s.SharedDirty, s.PrivateClean, s.PrivateDirty, s.Anonymous, s.Swap, s.SwapPss)
+}
+
+// field returns a pointer to the SmapsTotals member named by key, or nil if we don't track it.
+func (s *SmapsTotals) field(key []byte) *int64 {
+	switch string(key) {
+	case "Rss":
+		return &s.Rss
+	case "Pss":
+		return &s.Pss
+	case "Shared_Clean":
+		return &s.SharedClean
+	case "Shared_Dirty":
+		return &s.SharedDirty
+	case "Private_Clean":
+		return &s.PrivateClean
+	case "Private_Dirty":
+		return &s.PrivateDirty
+	case "Referenced":
+		return &s.Referenced
+	case "Anonymous":
+		return &s.Anonymous
+	case "AnonHugePages":
+		return &s.AnonHugePages
+	case "ShmemPmdMapped":
+		return &s.ShmemPmdMapped
+	case "Swap":
+		return &s.Swap
+	case "SwapPss":
+		return &s.SwapPss
+	case "Locked":
+		return &s.Locked
+	case "Shared_Hugetlb":
+		return &s.SharedHugetlb
+	case "Private_Hugetlb":
+		return &s.PrivateHugetlb
+	}
+	return nil
+}
+
+// Breakdown returns the full SmapsTotals for the watched process, read on demand.
+// This is not used by the Limit() loop, and is intended for diagnostics (e.g. after a kill).
+func (m *MemoryGuard) Breakdown() (SmapsTotals, error) {
+	if m.proc == nil {
+		return SmapsTotals{}, LimitNilProcessError
+	}
+	return getSmapsTotals(m.procRoot(), m.proc.Pid)
+}
+
+// getSmapsTotals takes a procfs root and a pid, and returns the SmapsTotals for it, or an error
+func getSmapsTotals(root string, pid int) (SmapsTotals, error) {
+	f, err := os.Open(procPath(root, pid, "smaps"))
+	if err != nil {
+		return SmapsTotals{}, err
+	}
+	defer f.Close()
+
+	return ParseSmapsTotals(f)
+}
+
+// ParseSmapsTotals reads smaps-formatted data from r, and returns the summed SmapsTotals.
+// r need not be a live procfs file, e.g. a captured smaps dump works just as well.
+func ParseSmapsTotals(r io.Reader) (SmapsTotals, error) {
+	var (
+		totals SmapsTotals
+		sep    = []byte(":")
+	)
+
+	s := newSmapsScanner(r)
+	for s.Scan() {
+		line := s.Bytes()
+		key, value, found := bytes.Cut(line, sep)
+		if !found {
+			continue
+		}
+		f := totals.field(key)
+		if f == nil {
+			continue
+		} else if f == &totals.Pss {
+			totals.Mappings++
+		}
+		size, err := parseKB(value)
+		if err != nil {
+			return SmapsTotals{}, err
+		}
+		if *f, err = addBytes(*f, size); err != nil {
+			return SmapsTotals{}, err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return SmapsTotals{}, err
+	}
+
+	return totals, nil
+}
+
+// ParsePss reads smaps-formatted data from r, and returns the sum of PSS page sizes in Bytes, or an error.
+// r need not be a live procfs file, e.g. a captured smaps dump works just as well.
+func ParsePss(r io.Reader) (int64, error) {
+	res, _, err := parsePss(r)
+	return res, err
+}
+
+// parsePss is ParsePss, also returning the number of mappings summed.
+func parsePss(r io.Reader) (int64, int, error) {
+	var (
+		res   int64
+		count int
+		pfx   = []byte("Pss:")
+	)
+
+	s := newSmapsScanner(r)
+	for s.Scan() {
+		line := s.Bytes()
+		if bytes.HasPrefix(line, pfx) {
+			size, err := parseKB(line[4:])
+			if err != nil {
+				return 0, 0, err
+			}
+			if res, err = addBytes(res, size); err != nil {
+				return 0, 0, err
+			}
+			count++
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	return res, count, nil
+}
+
+// Mapping is a single memory mapping from smaps.
+type Mapping struct {
+	// Address is the address range, e.g. "7ffd5e1c0000-7ffd5e1e1000"
+	Address string
+	// Perms are the permissions of the mapping, e.g. "rw-p", where the last is 's' if it is shared
+	Perms string
+	// Pathname is the file or pseudo-path (e.g. "[heap]") of the mapping, and may be empty for anonymous mappings
+	Pathname string
+	// Rss is the resident set size of the mapping, in Bytes
+	Rss int64
+	// Pss is the proportional set size of the mapping, in Bytes
+	Pss int64
+}
+
+// ParseMappings reads smaps-formatted data from r, and returns the Rss and Pss of each Mapping, in order.
+func ParseMappings(r io.Reader) ([]Mapping, error) {
+	var (
+		mappings []Mapping
+		current  *Mapping
+		pfx      = []byte("Pss:")
+		rssPfx   = []byte("Rss:")
+	)
+
+	s := newSmapsScanner(r)
+	for s.Scan() {
+		line := s.Bytes()
+		fields := bytes.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if !bytes.HasSuffix(fields[0], []byte(":")) {
+			// Mapping header: address perms offset dev inode [pathname]
+			mappings = append(mappings, Mapping{Address: string(fields[0])})
+			current = &mappings[len(mappings)-1]
+			if len(fields) >= 2 {
+				current.Perms = string(fields[1])
+			}
+			if len(fields) >= 6 {
+				current.Pathname = string(bytes.Join(fields[5:], []byte(" ")))
+			}
+		} else if current != nil && bytes.HasPrefix(line, pfx) {
+			size, err := parseKB(line[4:])
+			if err != nil {
+				return nil, err
+			}
+			current.Pss = size
+		} else if current != nil && bytes.HasPrefix(line, rssPfx) {
+			size, err := parseKB(line[4:])
+			if err != nil {
+				return nil, err
+			}
+			current.Rss = size
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+// topMappings takes a procfs root, a pid, and n, and returns the n largest Mappings by Pss, or an error
+func topMappings(root string, pid, n int) ([]Mapping, error) {
+	f, err := os.Open(procPath(root, pid, "smaps"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mappings, err := ParseMappings(f)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(mappings, func(a, b Mapping) int {
+		return cmp.Compare(b.Pss, a.Pss)
+	})
+	if len(mappings) > n {
+		mappings = mappings[:n]
+	}
+	return mappings, nil
+}
+
+// parseKB parses an smaps value, e.g. "   1234 kB", returning it in Bytes. Returns SmapsFormatError if it
+// isn't a non-negative number, or SmapsOverflowError if it's too big to be an int64 of Bytes.
+// It is hand-rolled, as this is the hot loop of every sample, and fmt.Sscanf allocates.
+func parseKB(value []byte) (int64, error) {
+	value = bytes.TrimLeft(value, " \t")
+	var (
+		size int64
+		i    int
+	)
+	for ; i < len(value) && value[i] >= '0' && value[i] <= '9'; i++ {
+		if size > (math.MaxInt64/1024-int64(value[i]-'0'))/10 {
+			return 0, SmapsOverflowError
+		}
+		size = size*10 + int64(value[i]-'0')
+	}
+	if i == 0 || (i < len(value) && value[i] != ' ' && value[i] != '\t') {
+		// No digits, or something like "12x"
+		return 0, SmapsFormatError
+	}
+	return size * 1024, nil
+}
+
+// addBytes returns total+size, or SmapsOverflowError if that would overflow. Both must be non-negative.
+func addBytes(total, size int64) (int64, error) {
+	if total > math.MaxInt64-size {
+		return 0, SmapsOverflowError
+	}
+	return total + size, nil
+}
@@ -0,0 +1,227 @@
+package memoryguard
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testSmaps = `55d0c0a00000-55d0c0a28000 r--p 00000000 fd:01 1234   /usr/bin/thing
+Size:                160 kB
+KernelPageSize:        4 kB
+MMUPageSize:           4 kB
+Rss:                 160 kB
+Pss:                  80 kB
+Shared_Clean:        160 kB
+Shared_Dirty:          0 kB
+Private_Clean:         0 kB
+Private_Dirty:         0 kB
+Referenced:          160 kB
+Anonymous:             0 kB
+AnonHugePages:         0 kB
+Swap:                  0 kB
+SwapPss:               0 kB
+Locked:                0 kB
+VmFlags: rd mr mw me dw sd
+7ffd5e1c0000-7ffd5e1e1000 rw-p 00000000 00:00 0      [stack]
+Size:                132 kB
+Rss:                  20 kB
+Pss:                  20 kB
+Shared_Clean:          0 kB
+Shared_Dirty:          0 kB
+Private_Clean:         0 kB
+Private_Dirty:        20 kB
+Referenced:           20 kB
+Anonymous:            20 kB
+Swap:                  8 kB
+SwapPss:               8 kB
+Locked:                0 kB
+VmFlags: rd wr mr mw me gd ac
+`
+
+func Test_ParseSmapsTotals(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When smaps data is parsed for totals", t, func() {
+		totals, err := ParseSmapsTotals(strings.NewReader(testSmaps))
+		So(err, ShouldBeNil)
+
+		Convey("the fields are summed across mappings, in Bytes", func() {
+			So(totals.Rss, ShouldEqual, 180*1024)
+			So(totals.Pss, ShouldEqual, 100*1024)
+			So(totals.SharedClean, ShouldEqual, 160*1024)
+			So(totals.PrivateDirty, ShouldEqual, 20*1024)
+			So(totals.Anonymous, ShouldEqual, 20*1024)
+			So(totals.Swap, ShouldEqual, 8*1024)
+		})
+	})
+}
+
+func Test_MemoryGuardBreakdown(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is asked for a Breakdown of us", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		totals, err := mg.Breakdown()
+
+		Convey("it doesn't return an error, and the totals look sane", func() {
+			So(err, ShouldBeNil)
+			So(totals.Pss, ShouldBeGreaterThan, 0)
+			So(totals.Rss, ShouldBeGreaterThanOrEqualTo, totals.Pss)
+		})
+	})
+}
+
+func Test_ParsePss(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When smaps data is parsed for PSS", t, func() {
+		pss, err := ParsePss(strings.NewReader(testSmaps))
+
+		Convey("the PSS is summed across mappings, in Bytes", func() {
+			So(err, ShouldBeNil)
+			So(pss, ShouldEqual, 100*1024)
+		})
+	})
+
+	Convey("When garbage smaps data is parsed for PSS", t, func() {
+		_, err := ParsePss(strings.NewReader("Pss: lots kB\n"))
+
+		Convey("it returns an error", func() {
+			So(err, ShouldEqual, SmapsFormatError)
+		})
+	})
+
+	Convey("When smaps data with a negative PSS is parsed for PSS", t, func() {
+		_, err := ParsePss(strings.NewReader("Pss: -4 kB\n"))
+
+		Convey("it returns an error", func() {
+			So(err, ShouldEqual, SmapsFormatError)
+		})
+	})
+
+	Convey("When smaps data with enormous PSS values is parsed", t, func() {
+		_, err := ParsePss(strings.NewReader("Pss: 9007199254740992 kB\n"))
+		Convey("a single value too big for Bytes returns an error", func() {
+			So(err, ShouldEqual, SmapsOverflowError)
+		})
+
+		_, err = ParsePss(strings.NewReader("Pss: 9007199254740000 kB\nPss: 9007199254740000 kB\n"))
+		Convey("values that sum too big for Bytes return an error", func() {
+			So(err, ShouldEqual, SmapsOverflowError)
+		})
+
+		_, err = ParseSmapsTotals(strings.NewReader("Rss: 9007199254740000 kB\nRss: 9007199254740000 kB\n"))
+		Convey("and totals too", func() {
+			So(err, ShouldEqual, SmapsOverflowError)
+		})
+	})
+}
+
+func Test_ParseOverlongLine(t *testing.T) {
+	Convey("When smaps data has a pathname longer than bufio.Scanner allows by default", t, func() {
+		long := "/" + strings.Repeat("x", 100*1024)
+		smaps := strings.Replace(testSmaps, "/usr/bin/thing", long, 1)
+
+		Convey("it is still parsed", func() {
+			pss, err := ParsePss(strings.NewReader(smaps))
+			So(err, ShouldBeNil)
+			So(pss, ShouldEqual, 100*1024)
+
+			totals, err := ParseSmapsTotals(strings.NewReader(smaps))
+			So(err, ShouldBeNil)
+			So(totals.Pss, ShouldEqual, 100*1024)
+
+			mappings, err := ParseMappings(strings.NewReader(smaps))
+			So(err, ShouldBeNil)
+			So(mappings[0].Pathname, ShouldEqual, long)
+		})
+	})
+
+	Convey("When smaps data has a line longer than we'll ever parse", t, func() {
+		_, err := ParsePss(strings.NewReader("Pss: 4 kB\n" + strings.Repeat("x", maxSmapsLine+1)))
+
+		Convey("it returns an error", func() {
+			So(err, ShouldEqual, bufio.ErrTooLong)
+		})
+	})
+}
+
+func Test_ParseMappings(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When smaps data is parsed for mappings", t, func() {
+		mappings, err := ParseMappings(strings.NewReader(testSmaps))
+
+		Convey("each mapping has its address, pathname, RSS, and PSS", func() {
+			So(err, ShouldBeNil)
+			So(mappings, ShouldResemble, []Mapping{
+				{Address: "55d0c0a00000-55d0c0a28000", Perms: "r--p", Pathname: "/usr/bin/thing", Rss: 160 * 1024, Pss: 80 * 1024},
+				{Address: "7ffd5e1c0000-7ffd5e1e1000", Perms: "rw-p", Pathname: "[stack]", Rss: 20 * 1024, Pss: 20 * 1024},
+			})
+		})
+	})
+}
+
+func Test_MemoryGuardReportTopMappings(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard with ReportTopMappings kills us", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.Interval = time.Millisecond
+		mg.ReportTopMappings = 3
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		mg.Limit(1024)   // 1KB
+
+		Convey("the KillEvent has the top mappings, largest first", func() {
+			<-mg.KillChan // wait for the kill
+			top := mg.KillEvent().TopMappings
+			So(top, ShouldHaveLength, 3)
+			So(top[0].Pss, ShouldBeGreaterThanOrEqualTo, top[1].Pss)
+			So(top[1].Pss, ShouldBeGreaterThanOrEqualTo, top[2].Pss)
+		})
+	})
+}
+
+// Benchmark_ParsePss (fmt.Sscanf)	     296	   4230144 ns/op	  420154 B/op	   20002 allocs/op
+// Benchmark_ParsePss            	    1182	   1015560 ns/op	    4128 B/op	       2 allocs/op
+func Benchmark_ParsePss(b *testing.B) {
+	// A large process has thousands of mappings
+	large := strings.Repeat(testSmaps, 2000)
+
+	for b.Loop() {
+		pss, err := ParsePss(strings.NewReader(large))
+		if err != nil {
+			b.Fatalf("Error! %s!\n", err)
+		}
+		if pss <= 0 {
+			b.Fatalf("Error! Pss is %d!\n", pss)
+		}
+	}
+}
+
+func Test_ParseKB(t *testing.T) {
+	Convey("When smaps values are parsed", t, func() {
+		for value, expected := range map[string]int64{
+			"  1234 kB": 1234 * 1024,
+			"0 kB":      0,
+			"\t7":       7 * 1024,
+		} {
+			size, err := parseKB([]byte(value))
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, expected)
+		}
+
+		for _, value := range []string{"", "   kB", "12x kB", "-1 kB", "1.5 kB"} {
+			_, err := parseKB([]byte(value))
+			So(err, ShouldEqual, SmapsFormatError)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package memoryguard
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// GuardStats is the periodic snapshot of a MemoryGuard logged every StatsFrequency, and given to OnStats and Subscribe.
+type GuardStats struct {
+	// Name is the name of the MemoryGuard
+	Name string
+	// Time is when the snapshot was taken
+	Time time.Time
+	// PSS is the last known value of the Metric, in Bytes
+	PSS int64
+	// Limit is the EffectiveLimit, or 0 if there is none (e.g. a monitor, or LimitRelative without a baseline)
+	Limit int64
+	// Errors is the number of consecutive sampling errors
+	Errors int
+	// Threads is the ThreadCount, if MaxThreads is set
+	Threads int
+	// Gauges are the values of the gauges from AddGauge, by name, if any
+	Gauges map[string]float64
+	// Labels are the Labels of the MemoryGuard, if any
+	Labels map[string]string
+}
+
+// Subscribe returns a new channel that receives the GuardStats every StatsFrequency, as OnStats does, and a func
+// that unsubscribes and closes it. It is also closed when the Limit goro exits. Any number of subscriptions may
+// be made, and sends never block the Limit goro: if a subscription's buffer is full, its oldest GuardStats is
+// dropped to make room, so a slow consumer always gets the latest. A buffer less than 1 is treated as 1.
+// The Gauges and Labels maps are shared by every subscription, so must not be modified.
+func (m *MemoryGuard) Subscribe(buffer int) (<-chan GuardStats, func()) {
+	c := m.statsSubs.subscribe(max(buffer, 1))
+	return c, func() { m.statsSubs.unsubscribe(c) }
+}
+
+// emitStats logs the stats line, calls OnStats if set, and publishes to every Subscribe, from the same snapshot.
+func (m *MemoryGuard) emitStats(name string, c *config, errors int) {
+	xss, max := m.lastPss.Load(), m.EffectiveLimit()
+	names, values := m.readGauges()
+	line, kv := gaugeLine(names, values)
+	var threads int
+	if c.maxThreads > 0 {
+		threads = m.ThreadCount()
+		line = fmt.Sprintf(" Threads: %d%s", threads, line)
+		kv = append([]any{"threads", threads}, kv...)
+	}
+	m.logf(LevelDebug, append([]any{"name", name, "pss", xss, "limit", max, "errors", errors}, kv...), "[%s] MemoryGuard: %s Limit %s Consecutive errors: %d%s\n", name, humanity.ByteFormat(xss), humanity.ByteFormat(max), errors, line)
+
+	stats := GuardStats{
+		Name:    name,
+		Time:    c.clock.Now(),
+		PSS:     xss,
+		Limit:   max,
+		Errors:  errors,
+		Threads: threads,
+		Gauges:  gaugeMapOf(names, values),
+		Labels:  maps.Clone(c.labels),
+	}
+	if f := c.onStats; f != nil {
+		m.callback(func() { f(stats) })
+	}
+	m.statsSubs.publish(stats, true)
+}
@@ -0,0 +1,42 @@
+package memoryguard
+
+import "time"
+
+// maxStoppedBackoff is the most Intervals between checks of a stopped process, as a power of 2. It is kept
+// small, as a process that resumes isn't sampled until the next check.
+const maxStoppedBackoff = 2
+
+// ProcessState returns the state of the process (e.g. 'R', 'S', 'T') from /proc/[pid]/stat, as last
+// seen by the Limit goro, or 0 if it hasn't been.
+func (m *MemoryGuard) ProcessState() byte {
+	return byte(m.state.Load())
+}
+
+// stopped records the state of the process, and if it is stopped (e.g. SIGSTOP, or a debugger), returns how
+// long to wait before checking again, doubling from 2 Intervals to 4 while it stays stopped, or else 0. A
+// stopped process's memory can't change, so sampling it is wasted effort. The state is one small read of stat,
+// so it is read every tick. Must only be called from the Limit goro.
+func (m *MemoryGuard) stopped(name string, c *config) time.Duration {
+	if m.cgroup != "" {
+		return 0
+	}
+	state, err := procState(c.procRoot, m.proc.Pid)
+	if err != nil {
+		return 0
+	}
+	m.state.Store(int32(state))
+
+	if state != 'T' && state != 't' {
+		if m.stops > 0 {
+			m.logf(LevelDebug, []any{"name", name, "state", string(state)}, "[%s] MemoryGuard process resumed, sampling\n", name)
+			m.stops = 0
+		}
+		return 0
+	}
+
+	if m.stops == 0 {
+		m.logf(LevelDebug, []any{"name", name, "state", string(state)}, "[%s] MemoryGuard process is stopped, not sampling until it resumes\n", name)
+	}
+	m.stops++
+	return c.interval << min(m.stops, maxStoppedBackoff)
+}
@@ -0,0 +1,85 @@
+package memoryguard
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// afterClock is a Clock whose After hands each wait to the test, which fires it.
+type afterClock struct {
+	afters chan afterCall
+}
+
+type afterCall struct {
+	d time.Duration
+	c chan time.Time
+}
+
+func (c *afterClock) Now() time.Time { return time.Now() }
+func (c *afterClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.afters <- afterCall{d, ch}
+	return ch
+}
+
+// next returns the duration of the next wait, and fires it.
+func (c *afterClock) next() time.Duration {
+	call := <-c.afters
+	call.c <- time.Now()
+	return call.d
+}
+
+func Test_MemoryGuardStopped(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is guarding a process that isn't stopped", t, func() {
+		cmd := exec.Command("sleep", "10")
+		So(cmd.Start(), ShouldBeNil)
+		defer cmd.Wait()
+		defer cmd.Process.Kill()
+
+		clock := &afterClock{afters: make(chan afterCall, 16)} // buffered, so the Limit goro never blocks on it
+		mg := New(cmd.Process, WithClock(clock), WithInterval(time.Second))
+		mg.StatsFrequency = 0
+		mg.nokill = true // set internal tunable to not actually kill anything.
+		So(mg.ProcessState(), ShouldEqual, 0)
+		So(mg.Limit(1024*1024*1024), ShouldBeNil) // 1GB
+		defer mg.CancelWait()
+
+		Convey("its state is read with every sample", func() {
+			So(clock.next(), ShouldEqual, time.Second)
+			So(clock.next(), ShouldEqual, time.Second) // the second wait isn't asked for until the first tick is processed
+			So(mg.ProcessState(), ShouldNotEqual, 0)
+			So(mg.SampleLatency().Count, ShouldEqual, 1)
+		})
+
+		Convey("once it is stopped, it isn't sampled, and is checked less often, until it resumes", func() {
+			So(cmd.Process.Signal(syscall.SIGSTOP), ShouldBeNil)
+			for s, _ := procState(DefaultProcRoot, cmd.Process.Pid); s != 'T'; s, _ = procState(DefaultProcRoot, cmd.Process.Pid) {
+				time.Sleep(time.Millisecond)
+			}
+
+			So(clock.next(), ShouldEqual, time.Second)
+			So(clock.next(), ShouldEqual, 2*time.Second)
+			So(clock.next(), ShouldEqual, 4*time.Second)
+			call := <-clock.afters                 // not fired until it has resumed
+			So(call.d, ShouldEqual, 4*time.Second) // at most
+			So(mg.ProcessState(), ShouldEqual, 'T')
+			So(mg.SampleLatency().Count, ShouldEqual, 0)
+
+			So(cmd.Process.Signal(syscall.SIGCONT), ShouldBeNil)
+			for s, _ := procState(DefaultProcRoot, cmd.Process.Pid); s == 'T'; s, _ = procState(DefaultProcRoot, cmd.Process.Pid) {
+				time.Sleep(time.Millisecond)
+			}
+			call.c <- time.Now()
+			So(clock.next(), ShouldEqual, time.Second) // back to every Interval
+			So(mg.ProcessState(), ShouldNotEqual, 'T')
+			So(mg.SampleLatency().Count, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,35 @@
+//go:build !windows && !plan9
+
+package memoryguard
+
+import "log/syslog"
+
+// SyslogFunc returns a LogFunc that sends events to w, as LOG_DEBUG for LevelDebug events, and LOG_ERR for
+// LevelError events, except breaches of the limit (those with a "trigger"), which are LOG_CRIT.
+// Errors writing to w are ignored, as there is nowhere left to report them.
+func SyslogFunc(w *syslog.Writer) LogFunc {
+	return func(level, msg string, kv ...any) {
+		if level != LevelError {
+			w.Debug(msg)
+		} else if isBreach(kv) {
+			w.Crit(msg)
+		} else {
+			w.Err(msg)
+		}
+	}
+}
+
+// WithSyslog sets the LogFunc to SyslogFunc(w).
+func WithSyslog(w *syslog.Writer) Option {
+	return WithLogFunc(SyslogFunc(w))
+}
+
+// isBreach returns true if the keys of kv include "trigger".
+func isBreach(kv []any) bool {
+	for i := 0; i < len(kv); i += 2 {
+		if kv[i] == "trigger" {
+			return true
+		}
+	}
+	return false
+}
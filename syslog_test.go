@@ -0,0 +1,44 @@
+//go:build !windows && !plan9
+
+package memoryguard
+
+import (
+	"log/syslog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_SyslogFunc(t *testing.T) {
+	Convey("When events are sent to syslog", t, func() {
+		path := filepath.Join(t.TempDir(), "log")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		w, err := syslog.Dial("unixgram", path, syslog.LOG_DAEMON, "memoryguard")
+		So(err, ShouldBeNil)
+		defer w.Close()
+
+		read := func() string {
+			buf := make([]byte, 1024)
+			n, _ := conn.Read(buf)
+			return string(buf[:n])
+		}
+		f := SyslogFunc(w)
+
+		Convey("they have the priority for their level", func() {
+			f(LevelDebug, "running", "name", "bob")
+			So(read(), ShouldStartWith, "<31>") // LOG_DAEMON|LOG_DEBUG
+			f(LevelError, "oops", "name", "bob", "error", "bad")
+			So(read(), ShouldStartWith, "<27>") // LOG_DAEMON|LOG_ERR
+			f(LevelError, "ALERT!", "name", "bob", "trigger", TriggerLimit)
+			msg := read()
+			So(msg, ShouldStartWith, "<26>") // LOG_DAEMON|LOG_CRIT
+			So(strings.HasSuffix(strings.TrimSpace(msg), "ALERT!"), ShouldBeTrue)
+		})
+	})
+}
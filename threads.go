@@ -0,0 +1,73 @@
+package memoryguard
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// ThreadCount returns the Threads count of the process read with the last sample, or 0 if MaxThreads isn't
+// set, or nothing has been sampled.
+func (m *MemoryGuard) ThreadCount() int {
+	return int(m.threads.Load())
+}
+
+// sampleThreads records the ThreadCount, logging any error.
+func (m *MemoryGuard) sampleThreads(c *config) {
+	n, err := getThreads(c.procRoot, m.proc.Pid)
+	if err != nil {
+		m.logf(LevelError, []any{"name", m.name(), "error", err}, "[%s] MemoryGuard status Error: %s\n", m.name(), err)
+		return
+	}
+	m.threads.Store(int64(n))
+}
+
+// tooManyThreads returns true if MaxThreads is set, the last ThreadCount is over it, and OnThreads isn't set.
+// If OnThreads is set, it is called instead, when the count climbs over MaxThreads. Only called by check.
+func (m *MemoryGuard) tooManyThreads(name string) bool {
+	c := m.conf()
+	if c.maxThreads <= 0 {
+		return false
+	}
+	n := m.ThreadCount()
+	over := n > c.maxThreads
+	if f := c.onThreads; f != nil {
+		if over && !m.threadsOver {
+			m.logf(LevelDebug, []any{"name", name, "threads", n, "maxthreads", c.maxThreads}, "[%s] MemoryGuard: %d Threads over MaxThreads %d\n", name, n, c.maxThreads)
+			m.callback(func() { f(n, c.maxThreads) })
+		}
+		m.threadsOver = over
+		return false
+	}
+	return over
+}
+
+// getThreads takes a procfs root and a pid, and returns the Threads count from status, or an error.
+func getThreads(root string, pid int) (int, error) {
+	f, err := os.Open(procPath(root, pid, "status"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	pfx := []byte("Threads:")
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Bytes()
+		if !bytes.HasPrefix(line, pfx) {
+			continue
+		}
+		n, err := strconv.Atoi(string(bytes.TrimSpace(line[len(pfx):])))
+		if err != nil {
+			return 0, StatusFormatError
+		}
+		return n, nil
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, StatusFormatError
+}
@@ -0,0 +1,84 @@
+package memoryguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_GetThreads(t *testing.T) {
+	Convey("When the Threads count is read for us", t, func() {
+		n, err := getThreads(DefaultProcRoot, os.Getpid())
+		Convey("it doesn't return an error, and we have more than one", func() {
+			So(err, ShouldBeNil)
+			So(n, ShouldBeGreaterThan, 1)
+		})
+	})
+
+	Convey("When the Threads count is read from a status without it", t, func() {
+		root := t.TempDir()
+		os.MkdirAll(filepath.Join(root, "42"), 0755)
+		os.WriteFile(filepath.Join(root, "42", "status"), []byte("Name:\tthing\nPid:\t42\n"), 0644)
+		_, err := getThreads(root, 42)
+		Convey("it returns an error", func() {
+			So(err, ShouldEqual, StatusFormatError)
+		})
+	})
+}
+
+func Test_MemoryGuardMaxThreads(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with a MaxThreads we're over", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us, WithTickSource(tick), WithMaxThreads(1, nil))
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		mg.StatsFrequency = time.Millisecond
+		stats, unsubscribe := mg.Subscribe(1)
+		defer unsubscribe()
+		So(mg.ThreadCount(), ShouldEqual, 0)
+		So(mg.Limit(400*1024*1024*1024), ShouldBeNil) // we won't actually hit this, right?
+		defer mg.Cancel()
+
+		Convey("it acts on the threads, even though we're under the limit, and reports them in the stats", func() {
+			tick <- time.Now()
+			<-mg.KillChan // wait for the kill
+			So(mg.Reason(), ShouldEqual, TriggerThreads)
+			So(mg.Reason().String(), ShouldEqual, "Threads")
+			So(mg.ThreadCount(), ShouldBeGreaterThan, 1)
+			for s := range stats {
+				if s.Threads > 0 {
+					So(s.Threads, ShouldEqual, mg.ThreadCount())
+					break
+				}
+			}
+		})
+	})
+
+	Convey("When a MemoryGuard is running on us with a MaxThreads we're over, and an OnThreads", t, func() {
+		calls := make(chan [2]int, 2)
+
+		us, _ := os.FindProcess(os.Getpid())
+		tick := make(chan time.Time)
+		mg := New(us, WithTickSource(tick), WithMaxThreads(1, func(threads, max int) { calls <- [2]int{threads, max} }))
+		mg.nokill = true                              // set internal tunable to not actually kill ourselves.
+		So(mg.Limit(400*1024*1024*1024), ShouldBeNil) // we won't actually hit this, right?
+		defer mg.Cancel()
+
+		Convey("it is called once, instead of acting on the process", func() {
+			tick <- time.Now()
+			tick <- time.Now()
+			tick <- time.Now() // the third tick can't be received until the second is processed
+			call := <-calls
+			So(call[0], ShouldBeGreaterThan, 1)
+			So(call[1], ShouldEqual, 1)
+			So(calls, ShouldBeEmpty)
+			So(mg.Reason(), ShouldEqual, TriggerNone)
+		})
+	})
+}
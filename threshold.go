@@ -0,0 +1,147 @@
+package memoryguard
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cognusion/go-humanity"
+)
+
+// Action is a function called when a threshold is crossed, with the PSS that crossed it
+// and the limit it is a fraction of.
+type Action func(pss, limit int64)
+
+// threshold is a fraction of the limit, and the Action to fire when it is crossed
+type threshold struct {
+	fraction float64
+	action   Action
+	internal bool // added by Limit, e.g. for OnDrain, and removed by Reset
+}
+
+// AddThreshold registers an Action to fire when the PSS exceeds the fraction of the limit
+// (e.g. 0.7 for 70%). Thresholds form an ordered ladder, and each check only the highest crossed
+// threshold fires, and only when the PSS has climbed into it from a lower one: remaining at
+// a level does not re-fire it, but dropping below it and climbing back will. If multiple thresholds
+// share a fraction, only the last one added will fire. Thresholds are evaluated before the
+// limit itself, so a threshold of 1.0 or higher will fire just before the process is killed.
+// Returns an error if the fraction is zero or negative, the action is nil, or if Limit() has
+// already been called.
+func (m *MemoryGuard) AddThreshold(fraction float64, action Action) error {
+	if fraction <= 0 || action == nil {
+		return ThresholdInvalidError
+	} else if m.limit.Load() != 0 {
+		return ThresholdAfterLimitError
+	}
+
+	m.addThreshold(threshold{fraction: fraction, action: action})
+	return nil
+}
+
+// addThreshold adds the threshold to the ladder, unconditionally.
+func (m *MemoryGuard) addThreshold(t threshold) {
+	m.thresholds = append(m.thresholds, t)
+	slices.SortStableFunc(m.thresholds, func(a, b threshold) int {
+		if a.fraction < b.fraction {
+			return -1
+		} else if a.fraction > b.fraction {
+			return 1
+		}
+		return 0
+	})
+}
+
+// crossedThreshold returns the index of the highest threshold crossed by pss, or -1 if none.
+func (m *MemoryGuard) crossedThreshold(pss, limit int64) int {
+	for i := len(m.thresholds) - 1; i >= 0; i-- {
+		if float64(pss) > m.thresholds[i].fraction*float64(limit) {
+			return i
+		}
+	}
+	return -1
+}
+
+// crossings are the times each threshold fraction was first crossed, for ThresholdCrossings.
+type crossings struct {
+	lock  sync.Mutex
+	times map[float64]time.Time
+}
+
+// ThresholdCrossings returns the time each threshold added by AddThreshold was first crossed, keyed by
+// its fraction. Thresholds that have not been crossed are absent. Unlike the Actions, a crossing is
+// only recorded once, even if the PSS drops below it and climbs back, so the result is a timeline of
+// how memory climbed. The returned map is a copy.
+func (m *MemoryGuard) ThresholdCrossings() map[float64]time.Time {
+	m.crossings.lock.Lock()
+	defer m.crossings.lock.Unlock()
+
+	return maps.Clone(m.crossings.times)
+}
+
+// recordCrossings records and logs the first crossing of each threshold at or below level.
+func (m *MemoryGuard) recordCrossings(name string, level int, pss, limit int64) {
+	m.crossings.lock.Lock()
+	defer m.crossings.lock.Unlock()
+
+	now := m.now()
+	for _, t := range m.thresholds[:level+1] {
+		if _, ok := m.crossings.times[t.fraction]; ok || t.internal {
+			continue
+		}
+		if m.crossings.times == nil {
+			m.crossings.times = make(map[float64]time.Time)
+		}
+		m.crossings.times[t.fraction] = now
+		m.logf(LevelDebug, []any{"name", name, "threshold", t.fraction, "pss", pss, "limit", limit, "time", now}, "[%s] MemoryGuard Threshold %.2f first crossed at %s: %s Limit %s\n", name, t.fraction, now.Format(time.RFC3339Nano), humanity.ByteFormat(pss), humanity.ByteFormat(limit))
+	}
+}
+
+// reset forgets all crossings.
+func (c *crossings) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.times = nil
+}
+
+// TimeOverWarn returns how long the samples have been continuously over WarnFraction of the limit, or 0 if
+// the last one wasn't, or WarnFraction is unset.
+func (m *MemoryGuard) TimeOverWarn() time.Duration {
+	return timeSince(m.now(), m.overWarn.Load())
+}
+
+// TimeOverLimit returns how long the samples have been continuously over the limit, or 0 if the last one
+// wasn't. This only accrues past one sample if the process wasn't killed, e.g. with DryRun, ReportOnly,
+// MinAvailable, or OnBreach declining.
+func (m *MemoryGuard) TimeOverLimit() time.Duration {
+	return timeSince(m.now(), m.overLimit.Load())
+}
+
+// trackOver starts or resets the TimeOverWarn and TimeOverLimit clocks for the sample.
+func (m *MemoryGuard) trackOver(pss, limit int64) {
+	if limit == unknownLimit {
+		return
+	}
+
+	now := m.now().UnixNano()
+	track := func(since *atomic.Int64, over bool) {
+		if !over {
+			since.Store(0)
+		} else {
+			since.CompareAndSwap(0, now)
+		}
+	}
+	warn := m.conf().warnFraction
+	track(&m.overWarn, warn > 0 && float64(pss) > warn*float64(limit))
+	track(&m.overLimit, pss > limit)
+}
+
+// timeSince returns the time from the UnixNano since to now, or 0 if since is 0.
+func timeSince(now time.Time, since int64) time.Duration {
+	if since == 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(0, since))
+}
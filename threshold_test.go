@@ -0,0 +1,143 @@
+package memoryguard
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MemoryGuardThresholds(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard is running on us with thresholds", t, func() {
+		var (
+			low, high atomic.Int64
+			tick      = make(chan time.Time)
+		)
+
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.TickSource = tick
+		So(mg.AddThreshold(0.5, func(int64, int64) { high.Add(1) }), ShouldBeNil)
+		So(mg.AddThreshold(0.0001, func(int64, int64) { low.Add(1) }), ShouldBeNil)
+		So(mg.Limit(400*1024*1024), ShouldBeNil) // we won't actually hit this, right?
+		defer mg.Cancel()
+
+		Convey("only the crossed threshold fires, and only once", func() {
+			for range 3 {
+				tick <- time.Now()
+			}
+			mg.CancelWait()
+
+			So(low.Load(), ShouldEqual, 1)
+			So(high.Load(), ShouldEqual, 0)
+		})
+
+		Convey("AddThreshold refuses after Limit", func() {
+			So(mg.AddThreshold(0.9, func(int64, int64) {}), ShouldEqual, ThresholdAfterLimitError)
+		})
+	})
+
+	Convey("When a MemoryGuard has an invalid threshold added, it refuses", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		So(mg.AddThreshold(0, func(int64, int64) {}), ShouldEqual, ThresholdInvalidError)
+		So(mg.AddThreshold(0.5, nil), ShouldEqual, ThresholdInvalidError)
+	})
+}
+
+func Test_CrossedThreshold(t *testing.T) {
+	Convey("When thresholds are added out of order", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.AddThreshold(0.9, func(int64, int64) {})
+		mg.AddThreshold(0.5, func(int64, int64) {})
+		mg.AddThreshold(0.7, func(int64, int64) {})
+
+		Convey("the highest crossed is found", func() {
+			So(mg.crossedThreshold(10, 100), ShouldEqual, -1)
+			So(mg.crossedThreshold(60, 100), ShouldEqual, 0)
+			So(mg.crossedThreshold(80, 100), ShouldEqual, 1)
+			So(mg.crossedThreshold(95, 100), ShouldEqual, 2)
+		})
+	})
+}
+
+func Test_MemoryGuardTimeOver(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating, dry-running MemoryGuard is over its warn fraction and limit for a while", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithWarnFraction(0.5), WithDryRun())
+		mg.SimulateChan = make(chan int64)
+		mg.OnBreach = func(*BreachContext) bool { return false } // never act, so we keep guarding
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		mg.SimulateChan <- 600
+		mg.SimulateChan <- 1200 // the second value can't be received until the first is processed
+		mg.SimulateChan <- 1200
+		So(mg.TimeOverWarn(), ShouldBeGreaterThan, 0)
+		So(mg.TimeOverLimit(), ShouldBeGreaterThan, 0)
+
+		time.Sleep(10 * time.Millisecond)
+		So(mg.TimeOverLimit(), ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+
+		mg.SimulateChan <- 700
+		mg.SimulateChan <- 700
+		So(mg.TimeOverLimit(), ShouldEqual, 0)
+		So(mg.TimeOverWarn(), ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+
+		mg.SimulateChan <- 100
+		mg.SimulateChan <- 100
+		So(mg.TimeOverWarn(), ShouldEqual, 0)
+	})
+}
+
+func Test_MemoryGuardThresholdCrossings(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard climbs through its thresholds", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		nothing := func(pss, limit int64) {}
+		So(mg.AddThreshold(0.5, nothing), ShouldBeNil)
+		So(mg.AddThreshold(0.8, nothing), ShouldBeNil)
+		So(mg.AddThreshold(0.9, nothing), ShouldBeNil)
+		So(mg.Limit(1000), ShouldBeNil)
+		So(mg.ThresholdCrossings(), ShouldBeEmpty)
+
+		mg.SimulateChan <- 600
+		mg.SimulateChan <- 600 // the second value can't be received until the first is processed
+		mg.SimulateChan <- 600
+		first := mg.ThresholdCrossings()
+		So(first, ShouldHaveLength, 1)
+		So(first, ShouldContainKey, 0.5)
+
+		Convey("jumping past several records them all, and dropping back doesn't change the first", func() {
+			mg.SimulateChan <- 100
+			mg.SimulateChan <- 950
+			mg.SimulateChan <- 950
+			crossings := mg.ThresholdCrossings()
+			So(crossings, ShouldHaveLength, 3)
+			So(crossings[0.5].Equal(first[0.5]), ShouldBeTrue)
+			So(crossings[0.8].Before(first[0.5]), ShouldBeFalse)
+			So(crossings[0.8].Equal(crossings[0.9]), ShouldBeTrue)
+
+			Convey("and Reset forgets them", func() {
+				mg.CancelWait()
+				<-mg.Done()
+				So(mg.Reset(), ShouldBeNil)
+				So(mg.ThresholdCrossings(), ShouldBeEmpty)
+			})
+		})
+
+		mg.CancelWait()
+	})
+}
@@ -0,0 +1,75 @@
+package memoryguard
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MappingType is a category of Mapping, for MappingWeights.
+type MappingType int
+
+const (
+	// MappingAnonymous is private memory not backed by a file, e.g. the heap and stacks.
+	MappingAnonymous MappingType = iota
+	// MappingFile is private memory backed by a file, e.g. executables and libraries, which can be
+	// reclaimed and re-read from the file.
+	MappingFile
+	// MappingShared is memory shared with other processes, e.g. shared memory and shared file mappings.
+	MappingShared
+)
+
+// String returns the name of the MappingType
+func (mt MappingType) String() string {
+	switch mt {
+	case MappingAnonymous:
+		return "Anonymous"
+	case MappingFile:
+		return "File"
+	case MappingShared:
+		return "Shared"
+	}
+	return "MappingType(" + strconv.Itoa(int(mt)) + ")"
+}
+
+// Type returns the MappingType of the Mapping: MappingShared if its Perms say so, MappingFile if its
+// Pathname is a file, otherwise MappingAnonymous.
+func (mp Mapping) Type() MappingType {
+	if strings.HasSuffix(mp.Perms, "s") {
+		return MappingShared
+	} else if strings.HasPrefix(mp.Pathname, "/") {
+		return MappingFile
+	}
+	return MappingAnonymous
+}
+
+// WeightedPss returns the sum of the Pss of the mappings, each multiplied by the weight for its
+// MappingType, or 1.0 if there is none, in Bytes.
+func WeightedPss(mappings []Mapping, weights map[MappingType]float64) int64 {
+	var total float64
+	for _, mp := range mappings {
+		w, ok := weights[mp.Type()]
+		if !ok {
+			w = 1.0
+		}
+		total += float64(mp.Pss) * w
+	}
+	return int64(total)
+}
+
+// getWeightedPssInfo takes a procfs root, a pid, and MappingWeights, and returns a SampleInfo with the
+// WeightedPss, or an error
+func getWeightedPssInfo(root string, pid int, weights map[MappingType]float64) (SampleInfo, error) {
+	path := procPath(root, pid, "smaps")
+	f, err := os.Open(path)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	defer f.Close()
+
+	mappings, err := ParseMappings(f)
+	if err != nil {
+		return SampleInfo{}, err
+	}
+	return SampleInfo{Bytes: WeightedPss(mappings, weights), Source: path, MappingCount: len(mappings)}, nil
+}
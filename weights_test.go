@@ -0,0 +1,60 @@
+package memoryguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testWeightedSmaps = `55d0c0a00000-55d0c0a28000 r--p 00000000 fd:01 1234   /usr/bin/thing
+Pss:                 100 kB
+55d0c1000000-55d0c1400000 rw-p 00000000 00:00 0      [heap]
+Pss:                 200 kB
+7f0000000000-7f0000100000 rw-s 00000000 00:01 5678   /dev/shm/thing
+Pss:                 400 kB
+`
+
+func Test_WeightedPss(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a MemoryGuard samples weighted PSS", t, func() {
+		root := t.TempDir()
+		os.WriteFile(filepath.Join(root, "stat"), []byte("cpu 0\n"), 0644)
+		os.Mkdir(filepath.Join(root, "1"), 0755)
+		os.WriteFile(filepath.Join(root, "1", "smaps"), []byte(testWeightedSmaps), 0644)
+		proc := &os.Process{Pid: 1}
+
+		Convey("each mapping is classified", func() {
+			mg := New(proc, WithProcRoot(root))
+			f, _ := os.Open(filepath.Join(root, "1", "smaps"))
+			defer f.Close()
+			mappings, err := ParseMappings(f)
+			So(err, ShouldBeNil)
+			So(mappings[0].Type(), ShouldEqual, MappingFile)
+			So(mappings[1].Type(), ShouldEqual, MappingAnonymous)
+			So(mappings[2].Type(), ShouldEqual, MappingShared)
+			So(MappingShared.String(), ShouldEqual, "Shared")
+			So(mg.PSS(), ShouldEqual, 700*1024)
+		})
+
+		Convey("default weights are plain PSS", func() {
+			mg := New(proc, WithProcRoot(root), WithMappingWeights(nil))
+			So(mg.PSS(), ShouldEqual, 700*1024)
+		})
+
+		Convey("weights are applied per MappingType", func() {
+			mg := New(proc, WithProcRoot(root), WithMappingWeights(map[MappingType]float64{
+				MappingFile:   0.2,
+				MappingShared: 0.5,
+			}))
+			info, err := mg.SampleInfo()
+			So(err, ShouldBeNil)
+			So(info.Bytes, ShouldEqual, (20+200+200)*1024)
+			So(info.MappingCount, ShouldEqual, 3)
+			So(MetricWeightedPSS.String(), ShouldEqual, "WeightedPSS")
+		})
+	})
+}
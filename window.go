@@ -0,0 +1,101 @@
+package memoryguard
+
+import (
+	"sync"
+	"time"
+)
+
+// window is a time-windowed buffer of samples, for AvgWindow.
+type window struct {
+	lock    sync.Mutex
+	samples []Sample
+}
+
+// add adds the sample at now, drops samples older than span, and returns the mean of the remainder.
+func (w *window) add(now time.Time, value int64, span time.Duration) int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.samples = append(w.samples, Sample{Time: now, Value: value})
+	return w.mean(now, span)
+}
+
+// average drops samples older than span, and returns the mean of the remainder, or 0 if there are none.
+func (w *window) average(now time.Time, span time.Duration) int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.mean(now, span)
+}
+
+// mean does the work for add and average. Callers must hold lock.
+func (w *window) mean(now time.Time, span time.Duration) int64 {
+	cutoff := now.Add(-span)
+	i := 0
+	for i < len(w.samples) && w.samples[i].Time.Before(cutoff) {
+		i++
+	}
+	w.samples = w.samples[i:]
+	if len(w.samples) == 0 {
+		return 0
+	}
+
+	var total int64
+	for _, s := range w.samples {
+		total += s.Value
+	}
+	return total / int64(len(w.samples))
+}
+
+// AvgPSSWindowed returns the mean of the samples taken within the last AvgWindow, or 0 if AvgWindow
+// is unset, or there are no samples within it.
+func (m *MemoryGuard) AvgPSSWindowed() int64 {
+	span := m.conf().avgWindow
+	if span <= 0 {
+		return 0
+	}
+	return m.avg.average(m.now(), span)
+}
+
+// lifetime is the running mean of every sample, for MeanPSS.
+type lifetime struct {
+	lock sync.Mutex
+	n    int64
+	mean float64
+}
+
+// add folds value into the mean, incrementally, so nothing needs to be kept or summed.
+func (l *lifetime) add(value int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.n++
+	l.mean += (float64(value) - l.mean) / float64(l.n)
+}
+
+// get returns the number of samples, and their mean.
+func (l *lifetime) get() (int64, int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.n, int64(l.mean)
+}
+
+// MeanPSS returns the mean of every sample since Limit (or the last Reset), or 0 if there are none.
+// Unlike AvgPSSWindowed, it needs no AvgWindow, and it survives Cancel, for end-of-run reporting.
+func (m *MemoryGuard) MeanPSS() int64 {
+	_, mean := m.lifetime.get()
+	return mean
+}
+
+// PeakPSS returns the highest sample since Limit (or the last Reset), or 0 if there are none.
+// It survives Cancel, for end-of-run reporting.
+func (m *MemoryGuard) PeakPSS() int64 {
+	return m.peak.Load()
+}
+
+// SampleCount returns the number of samples since Limit (or the last Reset).
+func (m *MemoryGuard) SampleCount() int64 {
+	n, _ := m.lifetime.get()
+	return n
+}
@@ -0,0 +1,88 @@
+package memoryguard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Window(t *testing.T) {
+	Convey("When samples are added to a window", t, func() {
+		var (
+			w   window
+			now = time.Now()
+		)
+		So(w.add(now, 100, time.Minute), ShouldEqual, 100)
+		So(w.add(now.Add(10*time.Second), 200, time.Minute), ShouldEqual, 150)
+		So(w.add(now.Add(20*time.Second), 300, time.Minute), ShouldEqual, 200)
+
+		Convey("samples older than the span are dropped", func() {
+			So(w.add(now.Add(65*time.Second), 500, time.Minute), ShouldEqual, 333) // (200+300+500)/3
+			So(w.average(now.Add(100*time.Second), time.Minute), ShouldEqual, 500)
+			So(w.average(now.Add(200*time.Second), time.Minute), ShouldEqual, 0)
+			So(w.samples, ShouldBeEmpty)
+		})
+	})
+}
+
+func Test_MemoryGuardAvgWindow(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard with an AvgWindow sees a spike", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us, WithAvgWindow(time.Hour))
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.AvgPSSWindowed(), ShouldEqual, 0)
+		So(mg.Limit(1000), ShouldBeNil)
+		defer mg.CancelWait()
+
+		mg.SimulateChan <- 500
+		mg.SimulateChan <- 500
+		mg.SimulateChan <- 1900 // average is 966
+		mg.SimulateChan <- 100  // the fourth value can't be received until the third is processed
+		So(mg.Reason(), ShouldEqual, TriggerNone)
+
+		mg.SimulateChan <- 5000 // average is 1600
+		<-mg.KillChan           // wait for the kill
+		So(mg.Reason(), ShouldEqual, TriggerLimit)
+		So(mg.KillEvent().Sample, ShouldEqual, 1600)
+		So(mg.AvgPSSWindowed(), ShouldEqual, 1600)
+	})
+}
+
+func Test_MemoryGuardLifetime(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	Convey("When a simulating MemoryGuard has seen some samples, and is cancelled", t, func() {
+		us, _ := os.FindProcess(os.Getpid())
+		mg := New(us)
+		mg.SimulateChan = make(chan int64)
+		mg.nokill = true // set internal tunable to not actually kill ourselves.
+		So(mg.MeanPSS(), ShouldEqual, 0)
+		So(mg.Limit(10000), ShouldBeNil)
+
+		mg.SimulateChan <- 100
+		mg.SimulateChan <- 900
+		mg.SimulateChan <- 200
+		mg.SimulateChan <- 400 // the fourth value can't be received until the third is processed
+		mg.CancelWait()
+		<-mg.Done()
+
+		Convey("the lifetime aggregates survive", func() {
+			So(mg.SampleCount(), ShouldEqual, 4)
+			So(mg.MeanPSS(), ShouldEqual, 400)
+			So(mg.PeakPSS(), ShouldEqual, 900)
+
+			Convey("until Reset", func() {
+				So(mg.Reset(), ShouldBeNil)
+				So(mg.SampleCount(), ShouldEqual, 0)
+				So(mg.MeanPSS(), ShouldEqual, 0)
+				So(mg.PeakPSS(), ShouldEqual, 0)
+			})
+		})
+	})
+}